@@ -0,0 +1,633 @@
+// versioned JSON API to control the program from other tools
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/veecue/GroupMatcher/matching"
+)
+
+// JSON representation of the whole project
+type apiProject struct {
+	matching.JSONStore
+	Options map[string]string `json:"options"`
+}
+
+// response of the match endpoint
+type apiMatchResult struct {
+	Solver     string     `json:"solver"`
+	Seed       int64      `json:"seed"`
+	Attempts   int        `json:"attempts"`
+	Duration   float64    `json:"duration"`
+	Quote      float64    `json:"quote"`
+	Percentage float64    `json:"percentage"`
+	Metrics    apiMetrics `json:"metrics"`
+	Warning    string     `json:"warning,omitempty"`
+}
+
+// JSON representation of matching.Metrics, the message describes them by the measure of the objective of the project
+type apiMetrics struct {
+	Objective  string `json:"objective"`
+	Got        []int  `json:"got"`
+	Unlisted   int    `json:"unlisted"`
+	Worst      int    `json:"worst"`
+	WorstCount int    `json:"worst_count"`
+	Sum        int    `json:"sum"`
+	Message    string `json:"message"`
+}
+
+// response of the rematch endpoint
+type apiRematchResult struct {
+	apiMatchResult
+	Moves []apiMove `json:"moves"`
+}
+
+// response of the improve endpoint
+type apiImproveResult struct {
+	QuoteBefore float64    `json:"quote_before"`
+	Quote       float64    `json:"quote"`
+	Percentage  float64    `json:"percentage"`
+	Metrics     apiMetrics `json:"metrics"`
+	Moves       []apiMove  `json:"moves"`
+}
+
+// JSON representation of matching.Move, the person and groups are given by their index before the rematch
+// To is -1 for persons that didn't get a group
+type apiMove struct {
+	Person  int    `json:"person"`
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// response of the validate endpoint
+type apiValidation struct {
+	Valid  bool   `json:"valid"`
+	Error  string `json:"error,omitempty"`
+	Groups string `json:"groups,omitempty"`
+	// the persons and groups that cause a conflict of the group sizes
+	Conflict *apiConflict `json:"conflict,omitempty"`
+	// the groups that can't be built, they are removed by matching with accept=true
+	Report *apiReport `json:"report,omitempty"`
+}
+
+// JSON representation of matching.Report, persons and groups are given by their index
+type apiReport struct {
+	Groups  []int `json:"groups"`
+	Persons []int `json:"persons"`
+}
+
+func newAPIReport(r *matching.Report, persons []*matching.Person, groups []*matching.Group) *apiReport {
+	report := &apiReport{Groups: make([]int, len(r.Groups)), Persons: make([]int, len(r.Persons))}
+	for i, g := range r.Groups {
+		report.Groups[i] = g.IndexIn(groups)
+	}
+	for i, p := range r.Persons {
+		report.Persons[i] = p.IndexIn(persons)
+	}
+	return report
+}
+
+// JSON representation of matching.Conflict, persons and groups are given by their index
+type apiConflict struct {
+	Persons     []int  `json:"persons"`
+	Groups      []int  `json:"groups"`
+	Missing     int    `json:"missing"`
+	Underfilled bool   `json:"underfilled"`
+	Message     string `json:"message"`
+}
+
+func newAPIConflict(c *matching.Conflict, persons []*matching.Person, groups []*matching.Group) *apiConflict {
+	conflict := &apiConflict{Persons: make([]int, len(c.Persons)), Groups: make([]int, len(c.Groups)), Missing: c.Missing, Underfilled: c.Underfilled, Message: conflictText(c)}
+	for i, p := range c.Persons {
+		conflict.Persons[i] = p.IndexIn(persons)
+	}
+	for i, g := range c.Groups {
+		conflict.Groups[i] = g.IndexIn(groups)
+	}
+	return conflict
+}
+
+// response on any error
+type apiError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// register all handlers of the API
+func registerAPI(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/project", apiHandler(handleAPIProject))
+	mux.HandleFunc("/api/v1/groups", apiHandler(handleAPIGroups))
+	mux.HandleFunc("/api/v1/persons", apiHandler(handleAPIPersons))
+	mux.HandleFunc("/api/v1/constraints", apiHandler(handleAPIConstraints))
+	mux.HandleFunc("/api/v1/balance", apiHandler(handleAPIBalance))
+	mux.HandleFunc("/api/v1/match", apiHandler(handleAPIMatch))
+	mux.HandleFunc("/api/v1/rematch", apiHandler(handleAPIRematch))
+	mux.HandleFunc("/api/v1/improve", apiHandler(handleAPIImprove))
+	mux.HandleFunc("/api/v1/validate", apiHandler(handleAPIValidate))
+}
+
+// wraps a handler of the API so that it only serves requests with the token given by -token and holds the lock of the
+// project, the GUI is updated after requests that may have changed the project
+func apiHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if *tokenFlag != "" && subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), []byte("Bearer "+*tokenFlag)) != 1 {
+			writeAPIError(res, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		projectLock.Lock()
+		// changes by the API can be undone in the window like the ones there
+		before := snapshot()
+		handler(res, req)
+		if req.Method != http.MethodGet {
+			hist.record("api", before)
+		}
+		projectLock.Unlock()
+		if req.Method != http.MethodGet && w != nil {
+			updateBody()
+		}
+	}
+}
+
+// returns an error if the server would listen on another address than localhost while the API has no token
+func checkListenAddress(address, token string) error {
+	if token != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return errors.New("listen_not_local")
+	}
+	return nil
+}
+
+// runs solve on a copy of the project without holding the lock of the project, the copy replaces the project
+// afterwards. Like a matching started in the window, it can be canceled there and no other matching or change of the
+// project is possible until it is finished.
+func runAPIMatching(ctx context.Context, solve func(ctx context.Context, project *matching.Project)) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	project := copyProject()
+	cancelMatching = cancel
+	projectLock.Unlock()
+	if w != nil {
+		updateBody()
+	}
+	solve(ctx, project)
+	projectLock.Lock()
+	setProject(project)
+	cancelMatching = nil
+}
+
+func writeJSON(res http.ResponseWriter, status int, v interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(v)
+}
+
+// write the error key together with its localized message
+func writeAPIError(res http.ResponseWriter, status int, err error) {
+	key, _ := splitError(err)
+	writeJSON(res, status, apiError{key, strings.Join(errorTexts(err), "\n")})
+}
+
+// JSON representation of the current groups, persons, constraints and balance rules
+func currentStore() matching.JSONStore {
+	store := matching.NewJSONStore(groups, persons)
+	store.Constraints = matching.NewJSONConstraints(constraints, persons)
+	store.Balance = matching.NewJSONBalance(balance, groups)
+	return store
+}
+
+// check the method of the request and that the project may be changed
+func checkAPIRequest(res http.ResponseWriter, req *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if req.Method == method {
+			if method != http.MethodGet && cancelMatching != nil {
+				writeAPIError(res, http.StatusConflict, errors.New("matching_running"))
+				return false
+			}
+			return true
+		}
+	}
+	res.Header().Set("Allow", strings.Join(methods, ", "))
+	writeAPIError(res, http.StatusMethodNotAllowed, errors.New("method_not_allowed"))
+	return false
+}
+
+// GET returns the whole project, PUT replaces it
+func handleAPIProject(res http.ResponseWriter, req *http.Request) {
+	if !checkAPIRequest(res, req, http.MethodGet, http.MethodPut) {
+		return
+	}
+	if req.Method == http.MethodGet {
+		p := apiProject{currentStore(), make(map[string]string)}
+		for _, pair := range options.AllPairs() {
+			p.Options[pair[0]] = pair[1]
+		}
+		writeJSON(res, http.StatusOK, p)
+		return
+	}
+
+	var p apiProject
+	err := json.NewDecoder(req.Body).Decode(&p)
+	if err != nil {
+		writeAPIError(res, http.StatusBadRequest, errors.New("syntax_error"))
+		return
+	}
+	err = p.CheckNames()
+	if err != nil {
+		writeAPIError(res, http.StatusUnprocessableEntity, err)
+		return
+	}
+	g, ps, err := p.Decode()
+	if err != nil {
+		writeAPIError(res, http.StatusUnprocessableEntity, err)
+		return
+	}
+	cs, err := p.DecodeConstraints(ps)
+	if err != nil {
+		writeAPIError(res, http.StatusUnprocessableEntity, err)
+		return
+	}
+	b, err := p.DecodeBalance(g)
+	if err != nil {
+		writeAPIError(res, http.StatusUnprocessableEntity, err)
+		return
+	}
+	project := matching.NewProject(g, ps)
+	project.Constraints, project.Balance = cs, b
+	project.Options = defaultOptions()
+	err = project.Options.SetMap(p.Options)
+	if err != nil {
+		writeAPIError(res, http.StatusUnprocessableEntity, err)
+		return
+	}
+	setProject(project)
+	writeJSON(res, http.StatusOK, p)
+}
+
+// GET returns all groups, POST adds a new group
+func handleAPIGroups(res http.ResponseWriter, req *http.Request) {
+	if !checkAPIRequest(res, req, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if req.Method == http.MethodGet {
+		writeJSON(res, http.StatusOK, matching.NewJSONStore(groups, persons).Groups)
+		return
+	}
+
+	var g matching.JSONGroup
+	err := json.NewDecoder(req.Body).Decode(&g)
+	if err != nil || g.MinSize < 0 || g.MinSize > g.Capacity {
+		writeAPIError(res, http.StatusBadRequest, errors.New("syntax_error"))
+		return
+	}
+	if g.Name == "" {
+		writeAPIError(res, http.StatusUnprocessableEntity, errors.New("empty_argument"))
+		return
+	}
+	if matching.FindGroupInSlot(g.Name, g.Slot, groups) != nil {
+		writeAPIError(res, http.StatusUnprocessableEntity, errors.New("group_name_not_unique"))
+		return
+	}
+	group := matching.NewGroup(g.Name, g.Capacity, g.MinSize)
+	group.Slot = g.Slot
+	for _, i := range g.Members {
+		if i < 0 || i >= len(persons) {
+			writeAPIError(res, http.StatusUnprocessableEntity, errors.New("index_out_of_range"))
+			return
+		}
+		if persons[i].IndexIn(group.Members) != -1 || persons[i].GroupInSlot(groups, g.Slot) != nil {
+			writeAPIError(res, http.StatusUnprocessableEntity, errors.New("person_twice_in_slot"))
+			return
+		}
+		group.Members = append(group.Members, persons[i])
+	}
+	groups = append(groups, group)
+	writeJSON(res, http.StatusCreated, g)
+}
+
+// GET returns all persons, POST adds a new person
+func handleAPIPersons(res http.ResponseWriter, req *http.Request) {
+	if !checkAPIRequest(res, req, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if req.Method == http.MethodGet {
+		writeJSON(res, http.StatusOK, matching.NewJSONStore(groups, persons).Persons)
+		return
+	}
+
+	var p matching.JSONPerson
+	err := json.NewDecoder(req.Body).Decode(&p)
+	if err != nil {
+		writeAPIError(res, http.StatusBadRequest, errors.New("syntax_error"))
+		return
+	}
+	if p.Name == "" || len(p.Preferences) == 0 {
+		writeAPIError(res, http.StatusUnprocessableEntity, errors.New("missing_argument"))
+		return
+	}
+	if matching.FindPerson(p.Name, persons) != nil {
+		writeAPIError(res, http.StatusUnprocessableEntity, errors.New("person_name_not_unique"))
+		return
+	}
+	prefs := make([]*matching.Group, len(p.Preferences))
+	for j, i := range p.Preferences {
+		if i < 0 || i >= len(groups) {
+			writeAPIError(res, http.StatusUnprocessableEntity, errors.New("index_out_of_range"))
+			return
+		}
+		prefs[j] = groups[i]
+	}
+	for key, value := range p.Attributes {
+		if key == "" || value == "" {
+			writeAPIError(res, http.StatusUnprocessableEntity, errors.New("empty_argument"))
+			return
+		}
+	}
+	person := matching.NewPerson(p.Name, prefs)
+	person.Attributes = p.Attributes
+	for _, i := range p.Friends {
+		if i < 0 || i >= len(persons) {
+			writeAPIError(res, http.StatusUnprocessableEntity, errors.New("index_out_of_range"))
+			return
+		}
+		person.Friends = append(person.Friends, persons[i])
+	}
+	persons = append(persons, person)
+	writeJSON(res, http.StatusCreated, p)
+}
+
+// GET returns all constraints, POST adds a new constraint
+func handleAPIConstraints(res http.ResponseWriter, req *http.Request) {
+	if !checkAPIRequest(res, req, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if req.Method == http.MethodGet {
+		writeJSON(res, http.StatusOK, currentStore().Constraints)
+		return
+	}
+
+	var c matching.JSONConstraint
+	err := json.NewDecoder(req.Body).Decode(&c)
+	if err != nil || (c.Kind != matching.Together && c.Kind != matching.Apart) {
+		writeAPIError(res, http.StatusBadRequest, errors.New("syntax_error"))
+		return
+	}
+	if len(c.Persons) < 2 {
+		writeAPIError(res, http.StatusUnprocessableEntity, errors.New("missing_argument"))
+		return
+	}
+	constraint := matching.NewConstraint(c.Kind, nil)
+	for _, i := range c.Persons {
+		if i < 0 || i >= len(persons) {
+			writeAPIError(res, http.StatusUnprocessableEntity, errors.New("index_out_of_range"))
+			return
+		}
+		if persons[i].IndexIn(constraint.Persons) != -1 {
+			writeAPIError(res, http.StatusUnprocessableEntity, errors.New("person_name_not_unique"))
+			return
+		}
+		constraint.Persons = append(constraint.Persons, persons[i])
+	}
+	constraints = append(constraints, constraint)
+	writeJSON(res, http.StatusCreated, c)
+}
+
+// GET returns all balance rules, POST adds a new balance rule
+func handleAPIBalance(res http.ResponseWriter, req *http.Request) {
+	if !checkAPIRequest(res, req, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if req.Method == http.MethodGet {
+		writeJSON(res, http.StatusOK, currentStore().Balance)
+		return
+	}
+
+	var r matching.JSONBalanceRule
+	err := json.NewDecoder(req.Body).Decode(&r)
+	if err != nil {
+		writeAPIError(res, http.StatusBadRequest, errors.New("syntax_error"))
+		return
+	}
+	rules, err := matching.JSONStore{Balance: []matching.JSONBalanceRule{r}}.DecodeBalance(groups)
+	if err != nil {
+		writeAPIError(res, http.StatusUnprocessableEntity, err)
+		return
+	}
+	balance = append(balance, rules[0])
+	writeJSON(res, http.StatusCreated, r)
+}
+
+// POST matches all unassigned persons, the body can contain options that are used for this run only
+// groups that can't be built are only removed with the query parameter accept=true, with incremental=true the persons
+// that are already assigned keep their groups
+func handleAPIMatch(res http.ResponseWriter, req *http.Request) {
+	if !checkAPIRequest(res, req, http.MethodPost) {
+		return
+	}
+	opts, ok := apiOptions(res, req)
+	if !ok {
+		return
+	}
+
+	m := matching.NewMatcher(matching.GetIncompletePersons(persons, groups), groups)
+	m.RankCosts = opts.RankCosts
+	m.Constraints, m.Balance = constraints, balance
+	m.Incremental = req.URL.Query().Get("incremental") == "true"
+	err, errGroups := m.CheckMatcher()
+	result := apiMatchResult{}
+	if err != nil && err.Error() == "group_deleted" && req.URL.Query().Get("accept") == "true" {
+		groups = m.Suggest().Apply(groups, persons)
+		result.Warning = err.Error()
+		m = matching.NewMatcher(matching.GetIncompletePersons(persons, groups), groups)
+		m.RankCosts = opts.RankCosts
+		m.Constraints, m.Balance = constraints, balance
+		m.Incremental = req.URL.Query().Get("incremental") == "true"
+		err, errGroups = m.CheckMatcher()
+	}
+	if err != nil {
+		writeAPIError(res, http.StatusUnprocessableEntity, checkError(err, errGroups))
+		return
+	}
+
+	// the matching is canceled if the client disconnects
+	var stats matching.Stats
+	runAPIMatching(req.Context(), func(ctx context.Context, project *matching.Project) {
+		m := projectMatcher(project, matching.GetIncompletePersons(project.Persons, project.Groups))
+		m.RankCosts = opts.RankCosts
+		m.Incremental = req.URL.Query().Get("incremental") == "true"
+		stats, err = m.Solve(ctx, opts)
+	})
+	if err == nil || err.Error() == "softtimeout" {
+		options.Record(stats)
+	}
+	if err != nil {
+		if err.Error() != "softtimeout" {
+			writeAPIError(res, http.StatusUnprocessableEntity, err)
+			return
+		}
+		result.Warning = err.Error()
+	}
+	result.Solver, result.Seed, result.Attempts, result.Duration = stats.Solver, stats.Seed, stats.Attempts, stats.Duration.Seconds()
+	result.Quote, result.Percentage = newMatcher(persons).CalcQuote()
+	result.Metrics = newAPIMetrics(opts.Objective)
+	writeJSON(res, http.StatusOK, result)
+}
+
+// returns the options of the project with the options in the body of the request, writes the error if they are invalid
+func apiOptions(res http.ResponseWriter, req *http.Request) (matching.Options, bool) {
+	opts := options
+	overrides := make(map[string]string)
+	err := json.NewDecoder(req.Body).Decode(&overrides)
+	if err != nil && req.ContentLength != 0 {
+		writeAPIError(res, http.StatusBadRequest, errors.New("syntax_error"))
+		return opts, false
+	}
+	err = opts.SetMap(overrides)
+	if err != nil {
+		writeAPIError(res, http.StatusUnprocessableEntity, err)
+		return opts, false
+	}
+	return opts, true
+}
+
+// POST matches the project again while moving as few assigned persons as possible, see matching.Rematch
+// the groups given by their index in the query parameter cancel (e.g. cancel=0,2) are cancelled and removed
+func handleAPIRematch(res http.ResponseWriter, req *http.Request) {
+	if !checkAPIRequest(res, req, http.MethodPost) {
+		return
+	}
+	opts, ok := apiOptions(res, req)
+	if !ok {
+		return
+	}
+	var cancelled []*matching.Group
+	var indices []int
+	if c := req.URL.Query().Get("cancel"); c != "" {
+		for _, s := range strings.Split(c, ",") {
+			i, err := strconv.Atoi(s)
+			if err != nil || i < 0 || i >= len(groups) {
+				writeAPIError(res, http.StatusUnprocessableEntity, errors.New("index_out_of_range"))
+				return
+			}
+			cancelled = append(cancelled, groups[i])
+			indices = append(indices, i)
+		}
+	}
+
+	m := matching.NewMatcher(matching.GetIncompletePersons(persons, groups), groups)
+	m.RankCosts = opts.RankCosts
+	m.Constraints, m.Balance = constraints, balance
+	err, errGroups := m.CheckRematch(cancelled)
+	if err != nil {
+		writeAPIError(res, http.StatusUnprocessableEntity, checkError(err, errGroups))
+		return
+	}
+
+	// the matching is canceled if the client disconnects
+	var moves []matching.Move
+	var stats matching.Stats
+	runAPIMatching(req.Context(), func(ctx context.Context, project *matching.Project) {
+		m := projectMatcher(project, matching.GetIncompletePersons(project.Persons, project.Groups))
+		m.RankCosts = opts.RankCosts
+		cancelled = cancelled[:0]
+		for _, i := range indices {
+			cancelled = append(cancelled, project.Groups[i])
+		}
+		moves, stats, err = m.Rematch(ctx, opts, cancelled)
+	})
+	var result apiRematchResult
+	if err != nil {
+		if err.Error() != "softtimeout" {
+			writeAPIError(res, http.StatusUnprocessableEntity, err)
+			return
+		}
+		result.Warning = err.Error()
+	}
+	result.Moves = newAPIMoves(moves)
+	groups = (&matching.Report{Groups: cancelled}).Apply(groups, persons)
+	options.Record(stats)
+	result.Solver, result.Seed, result.Attempts, result.Duration = stats.Solver, stats.Seed, stats.Attempts, stats.Duration.Seconds()
+	result.Quote, result.Percentage = newMatcher(persons).CalcQuote()
+	result.Metrics = newAPIMetrics(opts.Objective)
+	writeJSON(res, http.StatusOK, result)
+}
+
+// POST improves the current assignment within the soft timeout, see matching.Improve
+func handleAPIImprove(res http.ResponseWriter, req *http.Request) {
+	if !checkAPIRequest(res, req, http.MethodPost) {
+		return
+	}
+	opts, ok := apiOptions(res, req)
+	if !ok {
+		return
+	}
+
+	var result apiImproveResult
+	result.QuoteBefore, _ = newMatcher(persons).CalcQuote()
+	// the improvement is canceled if the client disconnects
+	var moves []matching.Move
+	runAPIMatching(req.Context(), func(ctx context.Context, project *matching.Project) {
+		ctx, cancel := context.WithTimeout(ctx, opts.SoftTimeout)
+		defer cancel()
+		moves = projectMatcher(project, project.Persons).Improve(ctx, opts)
+	})
+	result.Moves = newAPIMoves(moves)
+	result.Quote, result.Percentage = newMatcher(persons).CalcQuote()
+	result.Metrics = newAPIMetrics(opts.Objective)
+	writeJSON(res, http.StatusOK, result)
+}
+
+// returns the metrics of the current assignment described for the objective
+func newAPIMetrics(objective string) apiMetrics {
+	m := newMatcher(persons).CalcMetrics()
+	return apiMetrics{Objective: objective, Got: m.Got, Unlisted: m.Unlisted, Worst: m.Worst, WorstCount: m.WorstCount, Sum: m.Sum,
+		Message: metricsText(m, objective)}
+}
+
+// returns the JSON representation of the moves, the indices are valid as long as no group was removed
+func newAPIMoves(moves []matching.Move) []apiMove {
+	ret := make([]apiMove, len(moves))
+	for i, mv := range moves {
+		ret[i] = apiMove{Person: mv.Person.IndexIn(persons), From: mv.From.IndexIn(groups), To: -1, Reason: mv.Reason, Message: moveText(mv)}
+		if mv.To != nil {
+			ret[i].To = mv.To.IndexIn(groups)
+		}
+	}
+	return ret
+}
+
+// GET checks whether the unassigned persons can be matched without changing the project, see handleAPIMatch
+func handleAPIValidate(res http.ResponseWriter, req *http.Request) {
+	if !checkAPIRequest(res, req, http.MethodGet) {
+		return
+	}
+	m := newMatcher(matching.GetIncompletePersons(persons, groups))
+	m.Incremental = req.URL.Query().Get("incremental") == "true"
+	err, errGroups := m.CheckMatcher()
+	if err != nil {
+		validation := apiValidation{Error: err.Error(), Groups: errGroups}
+		if c, ok := err.(*matching.Conflict); ok {
+			validation.Conflict = newAPIConflict(c, persons, groups)
+		}
+		if r := m.Suggest(); r != nil {
+			validation.Report = newAPIReport(r, persons, groups)
+		}
+		writeJSON(res, http.StatusOK, validation)
+		return
+	}
+	writeJSON(res, http.StatusOK, apiValidation{Valid: true})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veecue/GroupMatcher/matching"
+)
+
+// sets a project with the given number of groupless persons as the current one and returns a server for the API
+func newTestAPI(t *testing.T, n int) *httptest.Server {
+	l = map[string]string{}
+	a, b := matching.NewGroup("A", n, 0), matching.NewGroup("B", n, 0)
+	var ps []*matching.Person
+	for i := 0; i < n; i++ {
+		ps = append(ps, matching.NewPerson("p"+strconv.Itoa(i), []*matching.Group{a, b}))
+	}
+	setProject(matching.NewProject([]*matching.Group{a, b}, ps))
+	options = defaultOptions()
+	mux := http.NewServeMux()
+	registerAPI(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// sends the request and returns the status and the error key of the response, the status is 0 if it fails
+func doAPIRequest(t *testing.T, method, url, token, body string) (int, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Error(err)
+		return 0, ""
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return 0, ""
+	}
+	defer res.Body.Close()
+	var apiErr apiError
+	json.NewDecoder(res.Body).Decode(&apiErr)
+	return res.StatusCode, apiErr.Error
+}
+
+func TestAPIToken(t *testing.T) {
+	srv := newTestAPI(t, 2)
+	*tokenFlag = "secret"
+	defer func() { *tokenFlag = "" }()
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"missing token", "", http.StatusUnauthorized},
+		{"wrong token", "guess", http.StatusUnauthorized},
+		{"token", "secret", http.StatusOK},
+	}
+	for _, test := range tests {
+		status, key := doAPIRequest(t, http.MethodGet, srv.URL+"/api/v1/project", test.token, "")
+		if status != test.status {
+			t.Errorf("%s: status %d (%s), want %d", test.name, status, key, test.status)
+		}
+	}
+}
+
+// changes of the project are refused while a matching started by the API is running
+func TestAPIMatchingRunning(t *testing.T) {
+	srv := newTestAPI(t, 60)
+	done := make(chan int)
+	go func() {
+		status, _ := doAPIRequest(t, http.MethodPost, srv.URL+"/api/v1/match", "",
+			`{"solver":"annealing","anneal_steps":"1000000000","soft_timeout":"1m","hard_timeout":"1m"}`)
+		done <- status
+	}()
+
+	// wait until the matching runs
+	for running := false; !running; time.Sleep(time.Millisecond) {
+		projectLock.Lock()
+		running = cancelMatching != nil
+		projectLock.Unlock()
+	}
+	if status, key := doAPIRequest(t, http.MethodPost, srv.URL+"/api/v1/groups", "", `{"name":"C","capacity":2}`); status != http.StatusConflict || key != "matching_running" {
+		t.Errorf("a change while matching returns %d (%s), want %d (matching_running)", status, key, http.StatusConflict)
+	}
+	if status, _ := doAPIRequest(t, http.MethodPost, srv.URL+"/api/v1/match", "", ""); status != http.StatusConflict {
+		t.Errorf("a second matching returns %d, want %d", status, http.StatusConflict)
+	}
+	if status, key := doAPIRequest(t, http.MethodGet, srv.URL+"/api/v1/project", "", ""); status != http.StatusOK {
+		t.Errorf("reading the project while matching returns %d (%s)", status, key)
+	}
+
+	projectLock.Lock()
+	cancelMatching()
+	projectLock.Unlock()
+	<-done
+	projectLock.Lock()
+	defer projectLock.Unlock()
+	if cancelMatching != nil || len(groups) != 2 {
+		t.Errorf("the project isn't restored after the matching: %d groups", len(groups))
+	}
+}
+
+// changes by the API are recorded in the history, requests that only read aren't
+func TestAPIHistory(t *testing.T) {
+	srv := newTestAPI(t, 2)
+	hist = history{}
+	for _, path := range []string{"/api/v1/project", "/api/v1/groups", "/api/v1/validate"} {
+		if status, key := doAPIRequest(t, http.MethodGet, srv.URL+path, "", ""); status != http.StatusOK {
+			t.Errorf("GET %s returns %d (%s)", path, status, key)
+		}
+	}
+	if len(hist.Undo) != 0 {
+		t.Fatalf("reading the project is recorded: %v", hist.Undo)
+	}
+	if status, key := doAPIRequest(t, http.MethodPost, srv.URL+"/api/v1/groups", "", `{"name":"C","capacity":2}`); status != http.StatusCreated {
+		t.Fatalf("adding a group returns %d (%s)", status, key)
+	}
+	if len(hist.Undo) != 1 || hist.Undo[0].Action != "api" {
+		t.Fatalf("adding a group is recorded as %v", hist.Undo)
+	}
+	if _, err := hist.undo(); err != nil || len(groups) != 2 {
+		t.Errorf("undo returns %v with %d groups", err, len(groups))
+	}
+}
+
+func TestCheckListenAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		token   string
+		local   bool
+	}{
+		{"localhost:0", "", true},
+		{"127.0.0.1:8080", "", true},
+		{"[::1]:8080", "", true},
+		{":8080", "", false},
+		{"0.0.0.0:8080", "", false},
+		{"example.com:8080", "", false},
+		{"0.0.0.0:8080", "secret", true},
+	}
+	for _, test := range tests {
+		if err := checkListenAddress(test.address, test.token); (err == nil) != test.local {
+			t.Errorf("%s with token %q returns %v", test.address, test.token, err)
+		}
+	}
+}
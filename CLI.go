@@ -0,0 +1,813 @@
+// command line interface to use the program without the GUI
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/veecue/GroupMatcher/matching"
+	"github.com/veecue/GroupMatcher/parseInput"
+)
+
+// exit codes of the command line interface for every error key
+var exitCodes = map[string]int{
+	// usage and files
+	"usage":            1,
+	"file_error":       2,
+	"listen_not_local": 3,
+
+	// reading projects
+	"empty_file":                   10,
+	"syntax_error":                 11,
+	"group_initializer_not_found":  12,
+	"person_initializer_not_found": 13,
+	"group_name_not_unique":        14,
+	"person_name_not_unique":       15,
+	"empty_argument":               16,
+	"missing_argument":             17,
+	"group_not_found":              18,
+	"unknown_setting":              19,
+	"invalid_setting":              20,
+	"solver_not_found":             21,
+	"invalid_rank_costs":           22,
+	"csv_column_missing":           23,
+	"csv_groups_error":             24,
+	"excel_error":                  25,
+	"person_not_found":             26,
+	"unsupported_version":          27,
+	"invalid_balance_rule":         28,
+	"index_out_of_range":           29,
+
+	// validation
+	"assigned_persons":          30,
+	"person_no_pref":            31,
+	"combination_overfilled":    32,
+	"err_matching_too_few_many": 33,
+	"group_deleted":             34,
+	"constraint_contradiction":  35,
+	"constraint_unsatisfiable":  36,
+	"balance_unsatisfiable":     37,
+	"combination_underfilled":   38,
+
+	// matching
+	"hardtimeout": 40,
+	"no_solution": 41,
+	"canceled":    42,
+
+	// writing projects and exports
+	"export_error":  50,
+	"groups_empty":  51,
+	"persons_empty": 52,
+	"legacy_name":   53,
+
+	// inconsistent JSON projects
+	"invalid_group_size":   60,
+	"duplicate_member":     61,
+	"person_twice_in_slot": 62,
+	"locked_not_member":    63,
+	"invalid_friend":       64,
+	"invalid_constraint":   65,
+}
+
+// returns true if the program was called with a command for the command line interface
+func isCommand(name string) bool {
+	switch name {
+	case "match", "rematch", "improve", "validate", "export", "stats", "serve", "import":
+		return true
+	}
+	return false
+}
+
+// runs the given command line and returns the exit code
+func runCommand(args []string) int {
+	var err error
+	switch args[0] {
+	case "match":
+		err = cmdMatch(args[1:])
+	case "rematch":
+		err = cmdRematch(args[1:])
+	case "improve":
+		err = cmdImprove(args[1:])
+	case "validate":
+		err = cmdValidate(args[1:])
+	case "export":
+		err = cmdExport(args[1:])
+	case "stats":
+		err = cmdStats(args[1:])
+	case "serve":
+		err = cmdServe(args[1:])
+	case "import":
+		err = cmdImport(args[1:])
+	}
+	if err == nil {
+		return 0
+	}
+	key, _ := splitError(err)
+	code, ok := exitCodes[key]
+	if !ok {
+		// e.g. errors of the operating system
+		fmt.Fprintln(os.Stderr, err)
+		return exitCodes["file_error"]
+	}
+	if key != "usage" {
+		printError(err)
+	}
+	return code
+}
+
+// splits an error of the form "key[: details]" into its parts, errors of imported files return the key of the first one
+func splitError(err error) (key string, details string) {
+	if errs := parseInput.AsParseErrors(err); errs != nil {
+		return errs[0].Key, ""
+	}
+	s := strings.SplitN(err.Error(), ": ", 2)
+	if len(s) == 2 {
+		details = s[1]
+	}
+	return s[0], details
+}
+
+// returns the localized messages of an error, one for every error in the lines of an imported file
+func errorTexts(err error) []string {
+	if errs := parseInput.AsParseErrors(err); errs != nil {
+		texts := make([]string, len(errs))
+		for i, e := range errs {
+			texts[i] = parseErrorText(e)
+		}
+		return texts
+	}
+	key, details := splitError(err)
+	text := l[key]
+	if text == "" {
+		text = key
+	}
+	return []string{text + details}
+}
+
+// returns the localized message of an error in an imported file with the token, its position and the expected syntax
+func parseErrorText(e *parseInput.ParseError) string {
+	text := l[e.Key]
+	if text == "" {
+		text = e.Key
+	}
+	if e.Token != "" {
+		text += ` "` + e.Token + `"`
+	}
+	if e.Line != 0 {
+		text += l["line"] + strconv.Itoa(e.Line)
+	}
+	if e.Column != 0 {
+		text += l["column"] + strconv.Itoa(e.Column)
+	}
+	if e.Hint != "" {
+		text += " (" + l[e.Hint] + ")"
+	}
+	return text
+}
+
+// prints the localized error messages
+func printError(err error) {
+	for _, text := range errorTexts(err) {
+		fmt.Fprintln(os.Stderr, l["error"]+": "+text)
+	}
+}
+
+// returns an error of CheckMatcher together with its details, conflicts of the group sizes are explained
+func checkError(err error, details string) error {
+	if c, ok := err.(*matching.Conflict); ok {
+		details = conflictText(c)
+	}
+	if details != "" {
+		return errors.New(err.Error() + ": " + details)
+	}
+	return err
+}
+
+// explains in the current language which persons and groups cause a conflict of the group sizes
+func conflictText(c *matching.Conflict) string {
+	names := c.PersonNames(", ")
+	if names == "" {
+		names = l["nobody"]
+	}
+	if c.Underfilled {
+		return fmt.Sprintf(l["conflict_underfilled"], c.GroupNames(", "), c.Missing, len(c.Persons), names)
+	}
+	return fmt.Sprintf(l["conflict_overfilled"], len(c.Persons), names, c.GroupNames(", "), c.Missing)
+}
+
+// explains in the current language who was moved by a rematch or an improvement from which group to which and why
+func moveText(mv matching.Move) string {
+	name := func(g *matching.Group) string {
+		if g == nil {
+			return l["unassigned"]
+		}
+		if g.Slot != "" {
+			return g.Slot + ": " + g.Name
+		}
+		return g.Name
+	}
+	return fmt.Sprintf(l["moved"], mv.Person.Name, name(mv.From), name(mv.To), l[mv.Reason])
+}
+
+// describes the metrics in the current language by the measure of the given objective, e.g. the worst choice for
+// leximin
+func metricsText(metrics matching.Metrics, objective string) string {
+	switch objective {
+	case "rank_maximal":
+		var parts []string
+		for i, n := range metrics.Got {
+			parts = append(parts, parseInput.ChoiceLabel(l, i)+": "+strconv.Itoa(n))
+		}
+		if metrics.Unlisted > 0 {
+			parts = append(parts, l["unlisted"]+": "+strconv.Itoa(metrics.Unlisted))
+		}
+		return strings.Join(parts, ", ")
+	case "leximin":
+		if metrics.WorstCount == 0 {
+			return ""
+		}
+		if metrics.Worst == 0 {
+			return fmt.Sprintf(l["metrics_worst"], l["unlisted"], metrics.WorstCount)
+		}
+		return fmt.Sprintf(l["metrics_worst"], parseInput.ChoiceLabel(l, metrics.Worst-1), metrics.WorstCount)
+	default:
+		return fmt.Sprintf(l["metrics_sum"], metrics.Sum)
+	}
+}
+
+// parses the flags of a command that can be given in front of and behind its arguments
+func parseFlags(fs *flag.FlagSet, args []string, nArgs int) ([]string, error) {
+	fs.SetOutput(os.Stderr)
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, errors.New("usage")
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) != nArgs {
+		fs.Usage()
+		return nil, errors.New("usage")
+	}
+	return positional, nil
+}
+
+// adds the flags to override the matching options of a project
+func optionFlags(fs *flag.FlagSet) func(*matching.Options) error {
+	solver := fs.String("solver", "", "the solver to use: "+strings.Join(matching.SolverNames(), ", "))
+	tries := fs.String("tries", "", "the number of parallel tries of the heuristic solver")
+	hardTimeout := fs.String("hard-timeout", "", "stop matching after this time, e.g. 1m")
+	softTimeout := fs.String("soft-timeout", "", "stop matching after this time if a solution was found, e.g. 10s")
+	rankCosts := fs.String("rank-costs", "", "linear, exponential or a comma separated list of costs")
+	moveCost := fs.String("move-cost", "", "the cost of moving an assigned person to another group when rematching")
+	annealTime := fs.String("anneal-time", "", "the time in which the annealing solver cools down, e.g. 5s")
+	cooling := fs.String("cooling", "", "the cooling schedule of the annealing solver: exponential or linear")
+	startTemperature := fs.String("start-temperature", "", "the start temperature of the annealing solver in rank costs")
+	seed := fs.String("seed", "", "the seed of the random numbers, 0 for a new one")
+	objective := fs.String("objective", "", "which assignment is the best: sum, rank_maximal or leximin")
+	return func(o *matching.Options) error {
+		for key, value := range map[string]string{"solver": *solver, "tries": *tries, "hard_timeout": *hardTimeout, "soft_timeout": *softTimeout, "rank_costs": *rankCosts, "move_cost": *moveCost,
+			"anneal_time": *annealTime, "cooling": *cooling, "start_temperature": *startTemperature, "seed": *seed, "objective": *objective} {
+			if value != "" {
+				err := o.Set(key, value)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// write data to the given path or stdout for "-"
+func writeOutput(path string, data []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// match all unassigned persons of a project and write the result
+func cmdMatch(args []string) error {
+	fs := flag.NewFlagSet("match", flag.ContinueOnError)
+	output := fs.String("o", "-", "the file to write the matched project to")
+	verbose := fs.Bool("v", false, "print the progress while matching")
+	accept := fs.Bool("accept", false, "remove the groups that can't be built from the project before matching")
+	incremental := fs.Bool("incremental", false, "keep the persons that are already assigned and only match the groupless ones")
+	setOptions := optionFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: GroupMatcher match [flags] input.gm")
+		fs.PrintDefaults()
+	}
+	files, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	project, err := loadProject(files[0])
+	if err != nil {
+		return err
+	}
+	err = setOptions(&project.Options)
+	if err != nil {
+		return err
+	}
+
+	m := projectMatcher(project, matching.GetIncompletePersons(project.Persons, project.Groups))
+	m.Incremental = *incremental
+	err, errGroups := m.CheckMatcher()
+	if err != nil && err.Error() == "group_deleted" && *accept {
+		// the groups are only removed on request, the matcher is created again for the remaining ones
+		fmt.Fprintln(os.Stderr, l["group_deleted"]+errGroups)
+		project.Groups = m.Suggest().Apply(project.Groups, project.Persons)
+		m = projectMatcher(project, matching.GetIncompletePersons(project.Persons, project.Groups))
+		m.Incremental = *incremental
+		err, errGroups = m.CheckMatcher()
+	}
+	if err != nil {
+		return checkError(err, errGroups)
+	}
+
+	// cancel on interrupt
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt)
+		<-c
+		cancel()
+	}()
+
+	opts := project.Options
+	if *verbose {
+		opts.Progress = func(p matching.Progress) {
+			fmt.Fprintf(os.Stderr, l["progress"]+"\n", p.Attempts, p.Found, p.BestQuote)
+		}
+	}
+	stats, err := m.Solve(ctx, opts)
+	if err != nil {
+		if err.Error() != "softtimeout" {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, l["softtimeout"])
+	}
+	project.Options.Record(stats)
+	quote, percentage := m.CalcQuote()
+	fmt.Fprintf(os.Stderr, "%s: %s, %d, %v\n", l["solver"], stats.Solver, stats.Attempts, stats.Duration)
+	fmt.Fprintf(os.Stderr, "%s: %d\n", l["seed"], stats.Seed)
+	fmt.Fprintf(os.Stderr, "%s: %.2f (%.2f %%)\n", l["rate"], quote, percentage)
+	fmt.Fprintln(os.Stderr, metricsText(m.CalcMetrics(), project.Options.Objective))
+
+	data, err := formatProjectFile(project, *output)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, data)
+}
+
+// match a project again after late registrations or cancelled groups while moving as few assigned persons as possible
+func cmdRematch(args []string) error {
+	fs := flag.NewFlagSet("rematch", flag.ContinueOnError)
+	output := fs.String("o", "-", "the file to write the matched project to")
+	verbose := fs.Bool("v", false, "print the progress while matching")
+	cancel := fs.String("cancel", "", "comma separated names of the groups that are cancelled (in every slot)")
+	setOptions := optionFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: GroupMatcher rematch [flags] input.gm")
+		fs.PrintDefaults()
+	}
+	files, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	project, err := loadProject(files[0])
+	if err != nil {
+		return err
+	}
+	err = setOptions(&project.Options)
+	if err != nil {
+		return err
+	}
+	var cancelled []*matching.Group
+	if *cancel != "" {
+		for _, name := range strings.Split(*cancel, ",") {
+			n := len(cancelled)
+			for _, g := range project.Groups {
+				if g.Name == strings.TrimSpace(name) {
+					cancelled = append(cancelled, g)
+				}
+			}
+			if len(cancelled) == n {
+				return errors.New(`group_not_found: "` + name + `"`)
+			}
+		}
+	}
+
+	m := projectMatcher(project, matching.GetIncompletePersons(project.Persons, project.Groups))
+	err, errGroups := m.CheckRematch(cancelled)
+	if err != nil {
+		return checkError(err, errGroups)
+	}
+
+	// cancel on interrupt
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt)
+		<-c
+		stop()
+	}()
+
+	opts := project.Options
+	if *verbose {
+		opts.Progress = func(p matching.Progress) {
+			fmt.Fprintf(os.Stderr, l["progress"]+"\n", p.Attempts, p.Found, p.BestQuote)
+		}
+	}
+	moves, stats, err := m.Rematch(ctx, opts, cancelled)
+	if err != nil {
+		if err.Error() != "softtimeout" {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, l["softtimeout"])
+	}
+	project.Groups = (&matching.Report{Groups: cancelled}).Apply(project.Groups, project.Persons)
+	project.Options.Record(stats)
+	for _, mv := range moves {
+		fmt.Fprintln(os.Stderr, moveText(mv))
+	}
+	quote, percentage := m.CalcQuote()
+	fmt.Fprintf(os.Stderr, "%s: %s, %d, %v\n", l["solver"], stats.Solver, stats.Attempts, stats.Duration)
+	fmt.Fprintf(os.Stderr, "%s: %d\n", l["seed"], stats.Seed)
+	fmt.Fprintf(os.Stderr, "%s: %d\n", l["moves"], len(moves))
+	fmt.Fprintf(os.Stderr, "%s: %.2f (%.2f %%)\n", l["rate"], quote, percentage)
+	fmt.Fprintln(os.Stderr, metricsText(m.CalcMetrics(), project.Options.Objective))
+
+	data, err := formatProjectFile(project, *output)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, data)
+}
+
+// improve the assignment of a project, which may also be made by hand, without violating its group sizes and rules
+func cmdImprove(args []string) error {
+	fs := flag.NewFlagSet("improve", flag.ContinueOnError)
+	output := fs.String("o", "-", "the file to write the improved project to")
+	setOptions := optionFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: GroupMatcher improve [flags] input.gm")
+		fs.PrintDefaults()
+	}
+	files, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	project, err := loadProject(files[0])
+	if err != nil {
+		return err
+	}
+	err = setOptions(&project.Options)
+	if err != nil {
+		return err
+	}
+
+	m := projectMatcher(project, project.Persons)
+	quoteBefore, _ := m.CalcQuote()
+	// the improvement stops at the soft timeout or on interrupt
+	ctx, stop := context.WithTimeout(context.Background(), project.Options.SoftTimeout)
+	defer stop()
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt)
+		<-c
+		stop()
+	}()
+	moves := m.Improve(ctx, project.Options)
+	for _, mv := range moves {
+		fmt.Fprintln(os.Stderr, moveText(mv))
+	}
+	quote, percentage := m.CalcQuote()
+	fmt.Fprintf(os.Stderr, "%s: %d\n", l["moves"], len(moves))
+	fmt.Fprintf(os.Stderr, l["improved"]+" (%.2f %%)\n", quoteBefore, quote, percentage)
+	fmt.Fprintln(os.Stderr, metricsText(m.CalcMetrics(), project.Options.Objective))
+
+	data, err := formatProjectFile(project, *output)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, data)
+}
+
+// check whether the unassigned persons of a project can be matched
+func cmdValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	incremental := fs.Bool("incremental", false, "keep the persons that are already assigned and only check the groupless ones")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: GroupMatcher validate input.gm")
+		fs.PrintDefaults()
+	}
+	files, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	project, err := loadProject(files[0])
+	if err != nil {
+		return err
+	}
+	m := projectMatcher(project, matching.GetIncompletePersons(project.Persons, project.Groups))
+	m.Incremental = *incremental
+	err, errGroups := m.CheckMatcher()
+	if err != nil {
+		return checkError(err, errGroups)
+	}
+	fmt.Fprintln(os.Stderr, l["valid"])
+	return nil
+}
+
+// export a project as excel, csv, json or .gm file
+func cmdExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "", "the file to export to, the format is taken from its extension")
+	format := fs.String("format", "", "the format of the export: xlsx, csv, json or gm")
+	total := fs.Bool("total", false, "export the groups and all preferences to excel")
+	version := fs.Int("version", 2, "the version of the .gm format, 1 has no header, quoting and metadata, older versions of the program can only read it if the project has no slots, attributes, friends, constraints, balance rules and locked assignments")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: GroupMatcher export [flags] -o output input.gm")
+		fs.PrintDefaults()
+	}
+	files, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		fs.Usage()
+		return errors.New("usage")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(*output), ".")
+	}
+
+	project, err := loadProject(files[0])
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "xlsx":
+		file, err := parseInput.FormatGroupsAndPersonsToExcel(project.Groups, project.Persons, project.Options.RankCosts, l, *total)
+		if err != nil {
+			return err
+		}
+		return file.Save(*output)
+	case "csv":
+		var w io.Writer = os.Stdout
+		if *output != "-" {
+			file, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			w = file
+		}
+		return parseInput.FormatGroupsAndPersonsToCSV(w, project.Groups, project.Persons, project.Options.RankCosts, l)
+	case "json":
+		data, err := matching.ProjectToJSON(project)
+		if err != nil {
+			return err
+		}
+		return writeOutput(*output, data)
+	case "gm":
+		text, err := parseInput.FormatProjectVersion(project, *version)
+		if err != nil {
+			return err
+		}
+		return writeOutput(*output, []byte(text))
+	default:
+		fs.Usage()
+		return errors.New("usage")
+	}
+}
+
+// parses a comma separated list of column numbers starting at 1
+func parseColumns(str string) ([]int, error) {
+	var columns []int
+	for _, s := range strings.Split(str, ",") {
+		c, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || c < 1 {
+			return nil, errors.New("usage")
+		}
+		columns = append(columns, c-1)
+	}
+	return columns, nil
+}
+
+// convert a .csv file, e.g. from an online form, or an excel workbook to a project
+func cmdImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	output := fs.String("o", "-", "the file to write the project to")
+	name := fs.Int("name", 1, "the column of the person names")
+	prefs := fs.String("prefs", "", "the columns of the preferences in rank order, e.g. 3,4,5 (default all other columns)")
+	assigned := fs.Int("assigned", 0, "the column of groups the persons are already assigned to (default none)")
+	attributes := fs.String("attrs", "", "the columns of attributes of the persons, e.g. 6,7, their headings are the keys")
+	groupsFile := fs.String("groups", "", "a .csv file with name, minimal and maximal size of every group")
+	minSize := fs.Int("min", 0, "the minimal size of groups that are not given in a groups file")
+	capacity := fs.Int("max", 0, "the maximal size of groups that are not given in a groups file (default no limit)")
+	comma := fs.String("comma", "", "the separator of the columns (default detected)")
+	noHeader := fs.Bool("no-header", false, "the first line doesn't contain headings")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: GroupMatcher import [flags] persons.csv|workbook.xlsx")
+		fmt.Fprintln(os.Stderr, "columns start at 1")
+		fs.PrintDefaults()
+	}
+	files, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	// excel workbooks contain the groups and don't need a mapping
+	if strings.EqualFold(filepath.Ext(files[0]), ".xlsx") {
+		project, err := loadProject(files[0])
+		if err != nil {
+			return err
+		}
+		data, err := formatProjectFile(project, *output)
+		if err != nil {
+			return err
+		}
+		return writeOutput(*output, data)
+	}
+
+	mapping := csvMapping()
+	mapping.Name, mapping.Assigned, mapping.Header = *name-1, *assigned-1, !*noHeader
+	mapping.MinSize, mapping.Capacity = *minSize, *capacity
+	if *prefs != "" {
+		mapping.Preferences, err = parseColumns(*prefs)
+		if err != nil {
+			fs.Usage()
+			return err
+		}
+	}
+	if *attributes != "" {
+		mapping.Attributes, err = parseColumns(*attributes)
+		if err != nil {
+			fs.Usage()
+			return err
+		}
+	}
+	if *comma != "" {
+		if *comma == "\\t" {
+			*comma = "\t"
+		}
+		mapping.Comma = []rune(*comma)[0]
+	}
+	if *name < 1 || *assigned < 0 || *minSize < 0 || *capacity < 0 || *capacity != 0 && *minSize > *capacity {
+		fs.Usage()
+		return errors.New("usage")
+	}
+
+	file, err := os.Open(files[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var project *matching.Project
+	if *groupsFile != "" {
+		var groups *os.File
+		groups, err = os.Open(*groupsFile)
+		if err != nil {
+			return err
+		}
+		defer groups.Close()
+		project, err = parseInput.ParseCSV(file, groups, mapping)
+	} else {
+		project, err = loadCSV(file, files[0], mapping)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := formatProjectFile(project, *output)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, data)
+}
+
+// print statistics about the assignment of a project
+func cmdStats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: GroupMatcher stats input.gm")
+		fs.PrintDefaults()
+	}
+	files, err := parseFlags(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	project, err := loadProject(files[0])
+	if err != nil {
+		return err
+	}
+	m := matching.NewMatcher(project.Persons, project.Groups)
+	m.RankCosts = project.Options.RankCosts
+	quote, percentage := m.CalcQuote()
+	metrics := m.CalcMetrics()
+	unassigned := matching.GetIncompletePersons(project.Persons, project.Groups)
+
+	fmt.Printf("%s: %.2f (%.2f %%)\n", l["rate"], quote, percentage)
+	fmt.Printf("%s: %d\n", l["persons"], len(project.Persons))
+	fmt.Printf("%s: %d\n", l["unassigned"], len(unassigned))
+	if len(project.Constraints) > 0 {
+		fmt.Printf("%s: %d/%d\n", l["constraints_violated"], len(matching.ViolatedConstraints(project.Constraints, project.Groups)), len(project.Constraints))
+	}
+	if len(project.Balance) > 0 {
+		violated := make(map[*matching.BalanceRule]bool)
+		for _, rules := range matching.BalanceViolations(project.Balance, project.Groups, project.Persons) {
+			for _, r := range rules {
+				violated[r] = true
+			}
+		}
+		fmt.Printf("%s: %d/%d\n", l["balance_violated"], len(violated), len(project.Balance))
+	}
+	if split, total := matching.SplitFriendships(project.Persons, project.Groups); total > 0 {
+		fmt.Printf("%s: %d/%d\n", l["friendships_split"], split, total)
+	}
+	for i, n := range metrics.Got {
+		fmt.Printf("%s: %d\n", parseInput.ChoiceLabel(l, i), n)
+	}
+	if metrics.Unlisted > 0 {
+		fmt.Printf("%s: %d\n", l["unlisted"], metrics.Unlisted)
+	}
+	if text := metricsText(metrics, "leximin"); text != "" {
+		fmt.Println(text)
+	}
+	fmt.Println(metricsText(metrics, "sum"))
+	fmt.Printf("%s:\n", l["groups"])
+	for _, g := range project.Groups {
+		cost := 0
+		for _, p := range g.Members {
+			cost += project.Options.RankCosts.Cost(p, g)
+		}
+		fmt.Printf("  %s, %s: %d\n", g.StringWithSize(), l["cost"], cost)
+	}
+	return nil
+}
+
+// serve the JSON API without GUI
+func cmdServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: GroupMatcher [-listen address] [-token token] serve [input.gm]")
+		fs.PrintDefaults()
+	}
+	files, err := parseFlags(fs, args, len(args))
+	if err != nil || len(files) > 1 {
+		fs.Usage()
+		return errors.New("usage")
+	}
+
+	setProject(matching.NewProject(make([]*matching.Group, 0), make([]*matching.Person, 0)))
+	options = defaultOptions()
+	if len(files) == 1 {
+		project, err := loadProject(files[0])
+		if err != nil {
+			return err
+		}
+		setProject(project)
+	}
+
+	if err := checkListenAddress(*listenFlag, *tokenFlag); err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	registerAPI(mux)
+	listener, err := net.Listen("tcp", *listenFlag)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "http://"+listener.Addr().String()+"/api/v1/project")
+	return http.Serve(listener, mux)
+}
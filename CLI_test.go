@@ -0,0 +1,86 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// returns the keys of the errors created in the non-test files of the package in dir by errors.New, tokenError and
+// lineError with a literal key, messages that aren't keys are translated into keys by their callers
+func errorKeys(t *testing.T, dir string) map[string]bool {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for name, file := range pkg.Files {
+			if strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) == 0 {
+					return true
+				}
+				switch f := call.Fun.(type) {
+				case *ast.SelectorExpr:
+					if x, ok := f.X.(*ast.Ident); !ok || x.Name != "errors" || f.Sel.Name != "New" {
+						return true
+					}
+				case *ast.Ident:
+					if f.Name != "tokenError" && f.Name != "lineError" {
+						return true
+					}
+				default:
+					return true
+				}
+				// keys with details start with a literal like "group_not_found: " + name
+				arg := call.Args[0]
+				if b, ok := arg.(*ast.BinaryExpr); ok {
+					arg = b.X
+				}
+				if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					key, err := strconv.Unquote(lit.Value)
+					if err != nil {
+						t.Fatal(err)
+					}
+					key = strings.SplitN(key, ":", 2)[0]
+					if strings.Trim(key, "abcdefghijklmnopqrstuvwxyz_") == "" {
+						keys[key] = true
+					}
+				}
+				return true
+			})
+		}
+	}
+	return keys
+}
+
+// every error of reading, checking and matching a project has its own exit code
+func TestExitCodes(t *testing.T) {
+	for _, dir := range []string{"parseInput", "matching"} {
+		for key := range errorKeys(t, dir) {
+			// a warning that comes with a result
+			if key == "softtimeout" {
+				continue
+			}
+			if _, ok := exitCodes[key]; !ok {
+				t.Errorf("%s: error %s has no exit code", dir, key)
+			}
+		}
+	}
+
+	codes := make(map[int]string)
+	for key, code := range exitCodes {
+		if other, ok := codes[code]; ok {
+			t.Errorf("%s and %s have the same exit code %d", key, other, code)
+		}
+		codes[code] = key
+	}
+}
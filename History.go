@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+
+	"github.com/veecue/GroupMatcher/matching"
+)
+
+// maximal number of changes that can be undone
+const maxHistory = 100
+
+// a change of the project by an action of the workspace, the project is stored before and after the change
+type historyEntry struct {
+	Action string               `json:"action"`
+	Before matching.JSONProject `json:"before"`
+	After  matching.JSONProject `json:"after"`
+}
+
+// the changes that can be undone and redone, the most recent ones are at the end
+type history struct {
+	Undo []historyEntry `json:"undo"`
+	Redo []historyEntry `json:"redo"`
+}
+
+// history of the current project
+var hist history
+
+// returns the current project in the representation stored in the history
+func snapshot() matching.JSONProject {
+	return matching.NewJSONProject(currentProject())
+}
+
+// returns whether both snapshots contain the same project
+func sameProject(a, b matching.JSONProject) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	return err == nil && bytes.Equal(x, y)
+}
+
+// adds the change of the project since before by the given action, nothing is recorded if the project didn't change
+// a new change can't be combined with undone ones, so they can't be redone anymore
+func (h *history) record(action string, before matching.JSONProject) {
+	after := snapshot()
+	if sameProject(before, after) {
+		return
+	}
+	h.Undo = append(h.Undo, historyEntry{Action: action, Before: before, After: after})
+	if len(h.Undo) > maxHistory {
+		h.Undo = h.Undo[len(h.Undo)-maxHistory:]
+	}
+	h.Redo = nil
+}
+
+// restores the project before the last change and returns the action of the change
+func (h *history) undo() (string, error) {
+	if len(h.Undo) == 0 {
+		return "", errors.New("nothing_to_undo")
+	}
+	e := h.Undo[len(h.Undo)-1]
+	project, err := e.Before.Decode()
+	if err != nil {
+		return "", err
+	}
+	setProject(project)
+	h.Undo = h.Undo[:len(h.Undo)-1]
+	h.Redo = append(h.Redo, e)
+	return e.Action, nil
+}
+
+// restores the project after the last undone change and returns the action of the change
+func (h *history) redo() (string, error) {
+	if len(h.Redo) == 0 {
+		return "", errors.New("nothing_to_redo")
+	}
+	e := h.Redo[len(h.Redo)-1]
+	project, err := e.After.Decode()
+	if err != nil {
+		return "", err
+	}
+	setProject(project)
+	h.Redo = h.Redo[:len(h.Redo)-1]
+	h.Undo = append(h.Undo, e)
+	return e.Action, nil
+}
+
+// returns whether the history ends with the given project, so it can be continued for it
+func (h *history) fits(project matching.JSONProject) bool {
+	if len(h.Undo) > 0 {
+		return sameProject(h.Undo[len(h.Undo)-1].After, project)
+	}
+	if len(h.Redo) > 0 {
+		return sameProject(h.Redo[len(h.Redo)-1].Before, project)
+	}
+	return true
+}
+
+// the history of a project is stored next to its file
+func historyPath(projectPath string) string {
+	return projectPath + ".history.json"
+}
+
+// stores the history next to the project file
+func (h *history) save(projectPath string) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(historyPath(projectPath), data, 0600)
+}
+
+// reads the history stored next to the project file, the history is empty if there is none or if the project was
+// changed without it (e.g. by another program)
+func loadHistory(projectPath string, project matching.JSONProject) history {
+	var h history
+	data, err := ioutil.ReadFile(historyPath(projectPath))
+	if err != nil || json.Unmarshal(data, &h) != nil || !h.fits(project) {
+		return history{}
+	}
+	return h
+}
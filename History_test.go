@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/veecue/GroupMatcher/matching"
+)
+
+// sets a project with the groups A and B and the groupless persons Anna and Ben as the current one
+func setTestProject() {
+	a, b := matching.NewGroup("A", 2, 0), matching.NewGroup("B", 2, 0)
+	setProject(&matching.Project{
+		Groups:  []*matching.Group{a, b},
+		Persons: []*matching.Person{matching.NewPerson("Anna", []*matching.Group{a, b}), matching.NewPerson("Ben", []*matching.Group{b, a})},
+		Options: matching.DefaultOptions(),
+	})
+}
+
+// assigns the current person with the given name to the current group with the given name and records the change
+func assignAndRecord(h *history, person, group string) {
+	before := snapshot()
+	g := matching.FindGroup(group, groups)
+	g.Members = append(g.Members, matching.FindPerson(person, persons))
+	h.record("addto", before)
+}
+
+// returns the name of the group of the current person with the given name, "" if it has none
+func groupOf(person string) string {
+	if g := matching.FindPerson(person, persons).GetGroup(groups); g != nil {
+		return g.Name
+	}
+	return ""
+}
+
+func TestHistory(t *testing.T) {
+	setTestProject()
+	var h history
+	if _, err := h.undo(); err == nil || err.Error() != "nothing_to_undo" {
+		t.Errorf("undo of an empty history returns %v", err)
+	}
+	if _, err := h.redo(); err == nil || err.Error() != "nothing_to_redo" {
+		t.Errorf("redo of an empty history returns %v", err)
+	}
+
+	// an action that doesn't change the project isn't recorded
+	h.record("edit", snapshot())
+	if len(h.Undo) != 0 {
+		t.Fatalf("an unchanged project is recorded: %v", h.Undo)
+	}
+
+	assignAndRecord(&h, "Anna", "A")
+	assignAndRecord(&h, "Ben", "B")
+	if len(h.Undo) != 2 || len(h.Redo) != 0 {
+		t.Fatalf("%d changes can be undone and %d redone, want 2 and 0", len(h.Undo), len(h.Redo))
+	}
+
+	action, err := h.undo()
+	if err != nil || action != "addto" {
+		t.Fatalf("undo returns %s, %v", action, err)
+	}
+	if groupOf("Anna") != "A" || groupOf("Ben") != "" {
+		t.Errorf("after undo Anna is in %q and Ben in %q", groupOf("Anna"), groupOf("Ben"))
+	}
+	if _, err := h.undo(); err != nil {
+		t.Fatal(err)
+	}
+	if groupOf("Anna") != "" {
+		t.Errorf("after the second undo Anna is in %q", groupOf("Anna"))
+	}
+
+	if _, err := h.redo(); err != nil {
+		t.Fatal(err)
+	}
+	if groupOf("Anna") != "A" || groupOf("Ben") != "" {
+		t.Errorf("after redo Anna is in %q and Ben in %q", groupOf("Anna"), groupOf("Ben"))
+	}
+	if len(h.Undo) != 1 || len(h.Redo) != 1 {
+		t.Fatalf("%d changes can be undone and %d redone, want 1 and 1", len(h.Undo), len(h.Redo))
+	}
+
+	// a new change discards the undone ones
+	assignAndRecord(&h, "Ben", "A")
+	if len(h.Redo) != 0 {
+		t.Errorf("%d changes can be redone after a new change", len(h.Redo))
+	}
+	if _, err := h.redo(); err == nil || err.Error() != "nothing_to_redo" {
+		t.Errorf("redo after a new change returns %v", err)
+	}
+	if groupOf("Ben") != "A" {
+		t.Errorf("Ben is in %q", groupOf("Ben"))
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	setTestProject()
+	var h history
+	for i := 0; i < maxHistory+5; i++ {
+		g := groups[i%2]
+		assignAndRecord(&h, "Anna", g.Name)
+		g.Members = nil
+	}
+	if len(h.Undo) != maxHistory {
+		t.Errorf("%d changes can be undone, want %d", len(h.Undo), maxHistory)
+	}
+}
+
+// only matchings that found an assignment change the project and are recorded
+func TestFinishMatching(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		recorded bool
+	}{
+		{name: "found", recorded: true},
+		{name: "stopped at the soft timeout", err: errors.New("softtimeout"), recorded: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "infeasible", err: errors.New("infeasible")},
+	}
+
+	for _, test := range tests {
+		setTestProject()
+		hist = history{}
+		before := snapshot()
+		project := copyProject()
+		project.Groups[0].Members = append(project.Groups[0].Members, project.Persons[0])
+		finishMatching("match", project, "", test.err, before)
+		matchingErrors = ""
+
+		if recorded := len(hist.Undo) == 1; recorded != test.recorded {
+			t.Errorf("%s: recorded %v, want %v", test.name, recorded, test.recorded)
+		}
+		if changed := groupOf("Anna") == "A"; changed != test.recorded {
+			t.Errorf("%s: project changed %v, want %v", test.name, changed, test.recorded)
+		}
+	}
+	hist = history{}
+}
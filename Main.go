@@ -3,13 +3,14 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
+	"io"
 	"io/ioutil"
 	"log"
-	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
@@ -20,6 +21,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"path"
@@ -49,9 +51,22 @@ var l map[string]string
 // set language per param
 var langFlag = flag.String("lang", "", "The language of the UI")
 
+// set the address of the http server per param, e.g. to make the API available to other computers
+var listenFlag = flag.String("listen", "localhost:0", "The address the http server listens on")
+
+// token of the API, which is required to listen on other addresses than localhost
+var tokenFlag = flag.String("token", "", "The bearer token that requests of the API have to send, required to listen on other addresses than localhost with serve")
+
+// set the default solver per param
+var solverFlag = flag.String("solver", matching.DefaultOptions().Solver, "The solver used for new projects")
+
 // current project
 var persons []*matching.Person
 var groups []*matching.Group
+var constraints []*matching.Constraint
+var balance []*matching.BalanceRule
+var options matching.Options
+var metadata map[string]string
 var filename string
 
 // buffer to save messages to be sent to astilectron
@@ -64,6 +79,22 @@ var projectPath string
 
 var w *astilectron.Window
 
+// guards the project and the state of the matching, which are changed by the window, the http handlers and the
+// finished matching
+var projectLock sync.Mutex
+
+// cancels the running matching, nil if there is none
+var cancelMatching context.CancelFunc
+
+// errors of the last finished matching that are displayed on the next update
+var matchingErrors string
+
+// persons moved by the last finished rematch that are displayed on the next update
+var matchingMoves string
+
+// groups that can't be built according to the last validation, removed when the user accepts them
+var report *matching.Report
+
 // scan language files from the locales directory and import them into the program
 func initLangs() {
 	langFiles, err := AssetDir("locales")
@@ -106,43 +137,130 @@ func initLangs() {
 	l = langs[tag]
 }
 
-func init() {
-	rand.Seed(int64(time.Now().Nanosecond()))
-}
-
-// create a user-readable and localized error message for input parsing
-func separateError(err string) (text string, with bool, line int) {
-	b := []byte(err)
-	for i := range b {
-		n, err := strconv.Atoi(string(b[i]))
-		if err != nil {
-			text = text + string(b[i])
-		} else {
-			with = true
-			line = line*10 + n
-		}
-	}
-	return
-}
-
 // store current project to autosafe location on program exit
 func autosafe() {
 	if projectPath != "" {
-		gm, err := parseInput.FormatGroupsAndPersons(groups, persons)
+		data, err := formatProjectFile(currentProject(), projectPath)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		ioutil.WriteFile(projectPath, []byte(gm), 0600)
+		ioutil.WriteFile(projectPath, data, 0600)
+		hist.save(projectPath)
 	}
 }
 
 // autosafe and exit program
 func exit() {
+	projectLock.Lock()
 	autosafe()
 	os.Exit(0)
 }
 
+// returns the project consisting of the current groups, persons and options
+func currentProject() *matching.Project {
+	return &matching.Project{Groups: groups, Persons: persons, Constraints: constraints, Balance: balance, Options: options, Metadata: metadata}
+}
+
+// returns a copy of the current project that shares nothing with it, the persons and groups keep their order
+func copyProject() *matching.Project {
+	project, err := snapshot().Decode()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return project
+}
+
+// returns the persons of the copy of the current project at the positions of the given persons of the current project
+func copiedPersons(project *matching.Project, selected []*matching.Person) []*matching.Person {
+	res := make([]*matching.Person, len(selected))
+	for i, p := range selected {
+		res[i] = project.Persons[p.IndexIn(persons)]
+	}
+	return res
+}
+
+// replaces the current project
+func setProject(project *matching.Project) {
+	groups, persons, constraints, balance, options = project.Groups, project.Persons, project.Constraints, project.Balance, project.Options
+	metadata = project.Metadata
+	report = nil
+}
+
+// creates a matcher for the given persons and the current groups that uses the options of the project
+func newMatcher(persons []*matching.Person) *matching.Matcher {
+	return projectMatcher(currentProject(), persons)
+}
+
+// creates a matcher for the given persons of the project that uses its groups, rules and options
+func projectMatcher(project *matching.Project, persons []*matching.Person) *matching.Matcher {
+	m := matching.NewMatcher(persons, project.Groups)
+	m.RankCosts = project.Options.RankCosts
+	m.Constraints, m.Balance = project.Constraints, project.Balance
+	return m
+}
+
+// returns the default options for new projects
+func defaultOptions() matching.Options {
+	o := matching.DefaultOptions()
+	if matching.GetSolver(*solverFlag) != nil {
+		o.Solver = *solverFlag
+	}
+	return o
+}
+
+// creates a select box to choose the solver of the current project
+func solverSelect() string {
+	res := bytes.Buffer{}
+	res.WriteString(`<select title="` + l["solver"] + `" onchange="astilectron.sendMessage('/?solver=' + this.value)">`)
+	for _, name := range matching.SolverNames() {
+		label := l["solver_"+name]
+		if label == "" {
+			label = name
+		}
+		if name == options.Solver {
+			res.WriteString(`<option value="` + name + `" selected>` + label + `</option>`)
+		} else {
+			res.WriteString(`<option value="` + name + `">` + label + `</option>`)
+		}
+	}
+	res.WriteString(`</select>`)
+	return res.String()
+}
+
+// returns a title attribute listing the attributes of the person, empty if it has none
+func attributesTitle(p *matching.Person) string {
+	if len(p.Attributes) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(p.Attributes))
+	for k, v := range p.Attributes {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return ` title="` + strings.Join(pairs, "\n") + `"`
+}
+
+// returns how many friends of the assigned person are in the same group, empty if it has no friends
+func friendsLabel(p *matching.Person, g *matching.Group) string {
+	if len(p.Friends) == 0 {
+		return ""
+	}
+	names := make([]string, len(p.Friends))
+	for i, f := range p.Friends {
+		names[i] = f.Name
+	}
+	return ` <span class="friends" title="` + l["friends"] + ": " + strings.Join(names, ", ") + `">` + strconv.Itoa(matching.FriendsInGroup(p, g)) + "/" + strconv.Itoa(len(p.Friends)) + `</span>`
+}
+
+// returns the group with the name of the wished group in the slot, nil if the slot doesn't offer it
+func slotGroup(pref *matching.Group, slot string) *matching.Group {
+	if pref.Slot == slot {
+		return pref
+	}
+	return matching.FindGroupInSlot(pref.Name, slot, groups)
+}
+
 // sorte the persons by alphabet for better UI
 func sortPersons() {
 	matching.Sort(persons)
@@ -249,30 +367,91 @@ func handleRoot(res http.ResponseWriter, req *http.Request) {
 	})
 }
 
+// returns the localized message of an error of CheckMatcher together with its details for the workspace
+func checkErrorText(err error, details string) string {
+	if c, ok := err.(*matching.Conflict); ok {
+		return l[err.Error()] + conflictText(c)
+	}
+	return l[err.Error()] + details
+}
+
 //handle changes
 func handleChanges(form url.Values, data string, calledByForm bool) string {
+	projectLock.Lock()
+	defer projectLock.Unlock()
 	res := bytes.Buffer{}
 
 	var errors bytes.Buffer
 	var notifications bytes.Buffer
 	var err error
 
+	// show the result of a finished matching
+	if matchingErrors != "" {
+		errors.WriteString(matchingErrors)
+		matchingErrors = ""
+	}
+	moves := matchingMoves
+	matchingMoves = ""
+
+	// stop a running matching
+	if form["cancel_match"] != nil && cancelMatching != nil {
+		cancelMatching()
+	}
+
+	// ignore all actions that change the project while a matching is running
+	if cancelMatching != nil {
+		for _, action := range []string{"reset", "import", "match", "rematch", "improve", "delfrom", "addto", "lock", "edit", "clear", "solver", "objective", "seed", "accept_report", "undo", "redo"} {
+			if form[action] != nil {
+				delete(form, action)
+				errors.WriteString(l["matching_running"] + "<br>")
+			}
+		}
+	}
+
 	// handle internal links
 	if form["internalLink"] != nil {
 		messages = append(messages, Message{"internalLink", form["internalLink"][0]})
 	}
 
-	// remove all persons from their groups
-	if form["reset"] != nil {
-		for i := range groups {
-			groups[i].Members = make([]*matching.Person, 0)
+	// the project before the changes of this request, they are added to the history at the end
+	before := snapshot()
+
+	// restore the project before or after the last change
+	if form["undo"] != nil || form["redo"] != nil {
+		var action string
+		if form["undo"] != nil {
+			action, err = hist.undo()
+		} else {
+			action, err = hist.redo()
 		}
+		if err != nil {
+			errors.WriteString(l[err.Error()] + "<br>")
+		} else if form["undo"] != nil {
+			notifications.WriteString(l["undone"] + l["history_"+action] + "<br>")
+		} else {
+			notifications.WriteString(l["redone"] + l["history_"+action] + "<br>")
+		}
+	}
+
+	// remove the groups that can't be built if the user accepts the report of the validation
+	if form["accept_report"] != nil && report != nil {
+		groups = report.Apply(groups, persons)
+		notifications.WriteString(l["report_accepted"] + "<br>")
+	}
+	if form["accept_report"] != nil || form["reject_report"] != nil {
+		report = nil
+	}
+
+	// remove all persons from their groups except the locked ones
+	if form["reset"] != nil {
+		matching.ResetGroups(groups)
 		notifications.WriteString(l["reseted"] + "<br>")
 	}
 
-	var importError string
-	var errorLine int
-	errorLine = 0
+	// the result of an import, the lines with errors are marked in the editmode
+	var imported bool
+	var importError error
+	var errorLines []int
 	if form["import"] != nil {
 		p := form.Get("import")
 		if p != "undefined" { // user pressed cancel, do nothing
@@ -280,9 +459,9 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 			// display any error messages from import
 			if err == nil {
 				projectPath = p
-				importError = "success"
+				imported = true
 			} else {
-				importError = err.Error()
+				importError = err
 			}
 		}
 	}
@@ -343,6 +522,33 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 		}
 	}
 
+	// select the solver for the current project
+	if form["solver"] != nil {
+		if matching.GetSolver(form.Get("solver")) != nil {
+			options.Solver = form.Get("solver")
+		} else {
+			errors.WriteString(l["solver_not_found"] + "<br>")
+		}
+	}
+
+	// select the objective for the current project
+	if form["objective"] != nil {
+		if options.Set("objective", form.Get("objective")) != nil {
+			errors.WriteString(l["invalid_setting"] + "<br>")
+		}
+	}
+
+	// set the seed of the random numbers, without one every matching gets a new seed
+	if form["seed"] != nil {
+		seed := strings.TrimSpace(form.Get("seed"))
+		if seed == "" {
+			seed = "0"
+		}
+		if options.Set("seed", seed) != nil {
+			errors.WriteString(l["invalid_setting"] + "<br>")
+		}
+	}
+
 	// match selected persons if requested
 	if form["match"] != nil {
 		var qPersons []*matching.Person
@@ -352,26 +558,94 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 		} else {
 			qPersons = persons
 		}
-		m := matching.NewMatcher(matching.GetGrouplessPersons(qPersons, groups), groups)
+		m := newMatcher(matching.GetIncompletePersons(qPersons, groups))
+		m.Incremental = form["incremental"] != nil
 		err, errGroups := m.CheckMatcher()
-		if err == nil || err.Error() == "group_deleted" {
+		if err == nil {
+			report = nil
+			project := copyProject()
+			m := projectMatcher(project, copiedPersons(project, m.Persons))
+			m.Incremental = form["incremental"] != nil
+			startMatching("match", project, func(ctx context.Context, opts matching.Options) (string, error) {
+				stats, err := m.Solve(ctx, opts)
+				if err == nil || err.Error() == "softtimeout" {
+					project.Options.Record(stats)
+				}
+				return "", err
+			}, before)
+		} else if err.Error() == "assigned_persons" {
+			errors.WriteString(l["assigned_persons"] + ` <a onclick="astilectron.sendMessage('/?match&incremental')">` + l["match_incremental"] + `</a><br>`)
+		} else if err.Error() == "group_deleted" {
+			// the project is only changed when the user accepts the report
+			report = m.Suggest()
+			errors.WriteString(l["group_deleted"] + errGroups + ` <a onclick="astilectron.sendMessage('/?accept_report')">` + l["accept_report"] + `</a> <a onclick="astilectron.sendMessage('/?reject_report')">` + l["reject_report"] + `</a><br>`)
+		} else {
+			errors.WriteString(checkErrorText(err, errGroups) + "<br>")
+		}
+	}
+
+	// match all persons again while moving as few assigned persons as possible, optionally without a cancelled group
+	if form["rematch"] != nil {
+		var cancelled []*matching.Group
+		if form.Get("cancel") != "" {
+			j, err := strconv.Atoi(form.Get("cancel"))
 			if err != nil {
-				errors.WriteString(l["group_deleted"] + errGroups + "<br>")
+				log.Fatal(err)
 			}
-			err = m.MatchManyAndTakeBest(50, time.Minute, 10*time.Second)
-			if err != nil {
-				errors.WriteString(l[err.Error()] + "<br>")
+			// with slots, the group is cancelled in every slot
+			for _, g := range groups {
+				if g.Name == groups[j].Name {
+					cancelled = append(cancelled, g)
+				}
 			}
-			groups, persons = m.Groups, m.Persons
-		} else {
-			if err.Error() == "combination_overfilled" {
-				errors.WriteString(l["combination_overfilled"] + errGroups + "<br>")
-			} else {
-				errors.WriteString(l[err.Error()] + "<br>")
+		}
+		m := newMatcher(matching.GetIncompletePersons(persons, groups))
+		err, errGroups := m.CheckRematch(cancelled)
+		if err == nil {
+			report = nil
+			project := copyProject()
+			m := projectMatcher(project, copiedPersons(project, m.Persons))
+			copiedCancelled := make([]*matching.Group, len(cancelled))
+			for i, g := range cancelled {
+				copiedCancelled[i] = project.Groups[g.IndexIn(groups)]
 			}
+			startMatching("rematch", project, func(ctx context.Context, opts matching.Options) (string, error) {
+				moves, stats, err := m.Rematch(ctx, opts, copiedCancelled)
+				if err != nil && err.Error() != "softtimeout" {
+					return "", err
+				}
+				project.Options.Record(stats)
+				project.Groups = (&matching.Report{Groups: copiedCancelled}).Apply(project.Groups, project.Persons)
+				text := l["moves"] + ": " + strconv.Itoa(len(moves)) + "<br>"
+				for _, mv := range moves {
+					text += template.HTMLEscapeString(moveText(mv)) + "<br>"
+				}
+				return text, err
+			}, before)
+		} else {
+			errors.WriteString(checkErrorText(err, errGroups) + "<br>")
 		}
 	}
 
+	// improve the current assignment within the soft timeout and show the quote before and after
+	if form["improve"] != nil {
+		report = nil
+		project := copyProject()
+		m := projectMatcher(project, project.Persons)
+		startMatching("improve", project, func(ctx context.Context, opts matching.Options) (string, error) {
+			ctx, cancel := context.WithTimeout(ctx, opts.SoftTimeout)
+			defer cancel()
+			quoteBefore, _ := m.CalcQuote()
+			moves := m.Improve(ctx, opts)
+			quoteAfter, _ := m.CalcQuote()
+			text := fmt.Sprintf(l["improved"], quoteBefore, quoteAfter) + "<br>" + template.HTMLEscapeString(metricsText(m.CalcMetrics(), opts.Objective)) + "<br>" + l["moves"] + ": " + strconv.Itoa(len(moves)) + "<br>"
+			for _, mv := range moves {
+				text += template.HTMLEscapeString(moveText(mv)) + "<br>"
+			}
+			return text, nil
+		}, before)
+	}
+
 	// delete the selected persons from the given groups
 	if form["delfrom"] != nil {
 		j, err := strconv.Atoi(form.Get("delfrom"))
@@ -380,12 +654,25 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 		}
 		for _, p := range listedPersons {
 			i := p.IndexIn(groups[j].Members)
-			if i != -1 {
+			if groups[j].IsLocked(p) {
+				errors.WriteString(l["member_locked"] + "<br>")
+			} else if i != -1 {
 				groups[j].Members = groups[j].Members[:i+copy(groups[j].Members[i:], groups[j].Members[i+1:])]
 			}
 		}
 	}
 
+	// lock or unlock the selected persons in the given group
+	if form["lock"] != nil {
+		j, err := strconv.Atoi(form.Get("lock"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, p := range listedPersons {
+			groups[j].SetLocked(p, !groups[j].IsLocked(p))
+		}
+	}
+
 	// add the selected persons to a given groups
 	if form["addto"] != nil {
 		j, err := strconv.Atoi(form.Get("addto"))
@@ -393,16 +680,16 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 			log.Fatal(err)
 		}
 		for _, p := range listedPersons {
-			var hasThisPreference bool
-			for k := range p.Preferences {
-				if p.Preferences[k] == groups[j] {
-					hasThisPreference = true
+			hasThisPreference := p.Rank(groups[j]) != -1
+			//with slots, a person must not attend a workshop twice
+			for _, g := range groups {
+				if g.Name == groups[j].Name && p.IndexIn(g.Members) != -1 {
+					hasThisPreference = false
 				}
 			}
-			//check if person has the wanted preference and is not assigned in case someone messes around with the links (DAU-safety) safety
-			if hasThisPreference && matching.FindPerson(p.Name, matching.GetGrouplessPersons(persons, groups)) != nil {
+			//check if person has the wanted preference and is not assigned (in the slot) in case someone messes around with the links (DAU-safety) safety
+			if hasThisPreference && p.GroupInSlot(groups, groups[j].Slot) == nil {
 				groups[j].Members = append(groups[j].Members, p)
-				hasThisPreference = false
 			}
 		}
 	}
@@ -412,15 +699,14 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 	editmodeContent := ""
 	if form["edit"] != nil {
 		if data != "" {
-			groupStore, personStore, err := parseInput.ParseGroupsAndPersons(strings.NewReader(data))
+			project, err := parseInput.ParseProject(strings.NewReader(data))
 			if err != nil {
 				editmode = true
-				importError = err.Error()
+				importError = err
 				editmodeContent = data
 			} else {
-				importError = "success"
-				groups = groupStore
-				persons = personStore
+				imported = true
+				setProject(project)
 
 				// avoid loosing data on sudden exit with no path being provided
 				if projectPath == "" {
@@ -431,41 +717,47 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 			}
 		} else {
 			editmode = true
-			editmodeContent, err = parseInput.FormatGroupsAndPersons(groups, persons)
+			editmodeContent, err = parseInput.FormatProject(currentProject())
 			if err != nil {
 				errors.WriteString(l[err.Error()] + "<br>")
 			}
 		}
 	}
 
-	if importError == "success" {
+	if imported {
 		notifications.WriteString(l["import_success"] + "<br>")
-	} else if importError != "" {
-		text, withLine, line := separateError(importError)
-		errString := l["import_error"] + l[text]
-		if withLine {
-			errorLine = line
-			errString = errString + l["line"] + strconv.Itoa(line)
+	} else if importError != nil {
+		for _, text := range errorTexts(importError) {
+			errors.WriteString(l["import_error"] + template.HTMLEscapeString(text) + "<br>")
 		}
-		errors.WriteString(errString)
+		errorLines = parseInput.AsParseErrors(importError).Lines()
 	}
 
 	// clear if in invalid state or requested
 	if (groups == nil || persons == nil) && errors.Len() == 0 {
 		projectPath = ""
-		groups = make([]*matching.Group, 0)
-		persons = make([]*matching.Person, 0)
+		setProject(matching.NewProject(make([]*matching.Group, 0), make([]*matching.Person, 0)))
+		options = defaultOptions()
 	}
 
 	if form["clear"] != nil {
 		projectPath = ""
-		groups = make([]*matching.Group, 0)
-		persons = make([]*matching.Person, 0)
+		setProject(matching.NewProject(make([]*matching.Group, 0), make([]*matching.Person, 0)))
+		options = defaultOptions()
 		notifications.WriteString(l["cleared"] + "<br>")
 	}
 
+	// add the change of the project to the history, a matching is added when it's finished
+	for _, action := range []string{"accept_report", "reset", "solver", "objective", "seed", "delfrom", "lock", "addto", "edit", "clear"} {
+		if form[action] != nil {
+			hist.record(action, before)
+			break
+		}
+	}
+
 	// calculate matching quote for display
-	quote_value, quoteInPercent := matching.NewMatcher(persons, groups).CalcQuote()
+	quote_value, quoteInPercent := newMatcher(persons).CalcQuote()
+	metrics := metricsText(newMatcher(persons).CalcMetrics(), options.Objective)
 
 	// sort persons before display
 	sortPersons()
@@ -476,19 +768,44 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 		res.WriteString(`<iframe name="form" style="display:none"></iframe>`)
 		res.WriteString(`<form action="/?edit" method="POST" target="form">`)
 		res.WriteString(`<div class="header"><div class="switch"><button type="submit">` + l["assign"] + `</button><a onclick="astilectron.sendMessage('?edit')">` + l["edit"] + `</a></div></div>`)
+	} else if cancelMatching != nil {
+		res.WriteString(`<div class="header"><div id="progress"><div id="progress_bar"></div><span id="progress_text">` + l["matching"] + `</span></div><ul><li><a onclick="astilectron.sendMessage('/?cancel_match')">` + l["cancel"] + `</a></li></ul></div>`)
 	} else {
-		res.WriteString(`<div class="header"><ul><li><a onclick="astilectron.sendMessage('/?reset')">` + l["reset"] + `</a></li><li><a onclick="astilectron.sendMessage('/?match')">` + l["match_selected"] + `</a></li></ul><div class="switch"><a onclick="astilectron.sendMessage('/')">` + l["assign"] + `</a><a class="inactive" onclick="astilectron.sendMessage('?edit')">` + l["edit"] + `</a></div></div>`)
+		res.WriteString(`<div class="header"><ul><li><a onclick="astilectron.sendMessage('/?reset')">` + l["reset"] + `</a></li><li><a onclick="astilectron.sendMessage('/?match')">` + l["match_selected"] + `</a></li><li><a onclick="astilectron.sendMessage('/?rematch')">` + l["rematch"] + `</a></li><li><a onclick="astilectron.sendMessage('/?improve')">` + l["improve"] + `</a></li><li>` + solverSelect() + `</li><li>` + objectiveSelect() + `</li><li>` + seedInput() + `</li></ul><div class="switch"><a onclick="astilectron.sendMessage('/')">` + l["assign"] + `</a><a class="inactive" onclick="astilectron.sendMessage('?edit')">` + l["edit"] + `</a></div></div>`)
 	}
 
 	// sidebar
 	res.WriteString(`<div class="sidebar">`)
 
 	res.WriteString(`<div id="scale_container"><div id="scale" style="height: ` + strconv.FormatFloat(quoteInPercent, 'f', 2, 64) + `%;"><p>` + strconv.FormatFloat(quote_value, 'f', 2, 64) + `</p></div></div>`)
+	if metrics != "" {
+		res.WriteString(`<div class="group metrics" title="` + l["objective_"+options.Objective] + `">` + template.HTMLEscapeString(metrics) + `</div>`)
+	}
+
+	// describe the violated constraints of every person
+	violated := make(map[*matching.Person]string)
+	for _, c := range matching.ViolatedConstraints(constraints, groups) {
+		names := make([]string, len(c.Persons))
+		for i, p := range c.Persons {
+			names[i] = p.Name
+		}
+		for _, p := range c.Persons {
+			violated[p] += l[c.Kind] + ": " + strings.Join(names, ", ") + "\n"
+		}
+	}
+
+	// describe the violated balance rules of every group
+	unbalanced := make(map[*matching.Group]string)
+	for g, rules := range matching.BalanceViolations(balance, groups, persons) {
+		for _, r := range rules {
+			unbalanced[g] += "\n" + r.String()
+		}
+	}
 
 	for i, group := range groups {
 		htmlid := fmt.Sprint("g", i)
 
-		var disliked bool
+		var disliked, violating bool
 		for _, m := range group.Members {
 			for j := int(len(m.Preferences)/2) + 1; j < len(m.Preferences); j++ {
 				if m.Preferences[j].Name == group.Name {
@@ -500,8 +817,13 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 				break
 			}
 		}
+		for _, m := range group.Members {
+			if violated[m] != "" {
+				violating = true
+			}
+		}
 
-		if (len(group.Members) < group.MinSize || len(group.Members) > group.Capacity) && !matching.AllEmpty(groups) {
+		if (len(group.Members) < group.MinSize || len(group.Members) > group.Capacity) && !matching.AllEmpty(groups) || violating || unbalanced[group] != "" {
 			res.WriteString(`<a class="unfitting group" href="#` + htmlid + `">` + group.StringWithSize() + `</a>`)
 		} else if disliked {
 			res.WriteString(`<a class="disliked group" href="#` + htmlid + `">` + group.StringWithSize() + `</a>`)
@@ -522,30 +844,63 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 	if notifications.Len() > 0 {
 		res.WriteString(`<div class="notifications">` + notifications.String() + `</div>`)
 	}
+	if moves != "" {
+		res.WriteString(`<div class="notifications moves">` + moves + `</div>`)
+	}
 
 	res.WriteString(`<div id="panels">`)
 	// list unassigned persons:
 	if !editmode {
 
-		grouplessPersons := matching.GetGrouplessPersons(persons, groups)
-		if !editmode && len(grouplessPersons) > 0 {
-			res.WriteString(`<table class="left panel">`)
-			res.WriteString(`<tr class="heading-big unassigned"><td colspan="5"><h3>` + l["unassigned"] + `</h3></td></tr>`)
-			res.WriteString(`<tr class="headings-middle unassigned"><th><span class="spacer"></span></th><th>` + l["name"] + `</th><th>` + l["1stchoice"] + `</th><th>` + l["2ndchoice"] + `</th><th>` + l["3rdchoice"] + `</th></tr>`)
+		// create a column for every preference of the person with the most preferences
+		nChoices := matching.MaxPreferences(persons)
+		colspan := strconv.Itoa(nChoices + 2)
+		choiceHeadings := ""
+		for i := 0; i < nChoices; i++ {
+			choiceHeadings += `<th>` + parseInput.ChoiceLabel(l, i) + `</th>`
+		}
+
+		// with slots, the persons without group are listed for every slot and their wishes link to the groups of the slot
+		slots := matching.Slots(groups)
+		if slots == nil {
+			slots = []string{""}
+		}
+		leftPanel := false
+		for _, slot := range slots {
+			grouplessPersons := matching.GetGrouplessPersonsInSlot(persons, groups, slot)
+			if editmode || len(grouplessPersons) == 0 {
+				continue
+			}
+			if !leftPanel {
+				res.WriteString(`<table class="left panel">`)
+				leftPanel = true
+			}
+			heading := l["unassigned"]
+			if slot != "" {
+				heading += ": " + slot
+			}
+			res.WriteString(`<tr class="heading-big unassigned"><td colspan="` + colspan + `"><h3>` + heading + `</h3></td></tr>`)
+			res.WriteString(`<tr class="headings-middle unassigned"><th><span class="spacer"></span></th><th>` + l["name"] + `</th>` + choiceHeadings + `</tr>`)
 			for i, person := range grouplessPersons {
-				res.WriteString(`<tr class="person unassigned"><td><!--input type="checkbox" name="person` + strconv.Itoa(i) + `"--></td><td>` + person.Name + `</td>`)
+				res.WriteString(`<tr class="person unassigned"><td><!--input type="checkbox" name="person` + strconv.Itoa(i) + `"--></td><td` + attributesTitle(person) + `>` + person.Name + `</td>`)
 
-				for i := 0; i < 3; i++ {
-					if i >= len(person.Preferences) {
+				for i := 0; i < nChoices; i++ {
+					var pref *matching.Group
+					if i < len(person.Preferences) {
+						pref = slotGroup(person.Preferences[i], slot)
+					}
+					if pref == nil {
 						res.WriteString(`<td>--------</td>`)
 					} else {
-						prefID := person.Preferences[i].IndexIn(groups)
-						res.WriteString(`<td><a onclick="astilectron.sendMessage('?person` + strconv.Itoa(person.IndexIn(persons)) + `&addto=` + strconv.Itoa(prefID) + `')" title="` + l["add_to_group"] + `">` + person.Preferences[i].StringWithSize() + `</a></td>`)
+						prefID := pref.IndexIn(groups)
+						res.WriteString(`<td><a onclick="astilectron.sendMessage('?person` + strconv.Itoa(person.IndexIn(persons)) + `&addto=` + strconv.Itoa(prefID) + `')" title="` + l["add_to_group"] + `">` + pref.StringWithSize() + `</a></td>`)
 					}
 				}
 
 				res.WriteString("</tr>")
 			}
+		}
+		if leftPanel {
 			res.Write([]byte(`</table>`))
 		}
 
@@ -555,16 +910,33 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 			for i, group := range groups {
 				htmlid := fmt.Sprint("g", i)
 				res.Write([]byte(``))
-				res.WriteString(`<tr class="heading-big assigned"><td colspan="5"><h3 id="` + htmlid + `">` + group.StringWithSize() + `</h3></td></tr>`)
-				res.WriteString(`<tr class="headings-middle assigned"><th><span class="spacer"></span></th><th>` + l["name"] + `</th><th>` + l["1stchoice"] + `</th><th>` + l["2ndchoice"] + `</th><th>` + l["3rdchoice"] + `</th></tr>`)
+				cancelLink := ` <a class="cancel_group" onclick="astilectron.sendMessage('/?rematch&cancel=` + strconv.Itoa(i) + `')" title="` + l["cancel_group"] + `">&#10005;</a>`
+				if unbalanced[group] != "" {
+					res.WriteString(`<tr class="heading-big assigned"><td colspan="` + colspan + `"><h3 id="` + htmlid + `" class="unbalanced" title="` + l["balance_violated"] + unbalanced[group] + `">` + group.StringWithSize() + cancelLink + `</h3></td></tr>`)
+				} else {
+					res.WriteString(`<tr class="heading-big assigned"><td colspan="` + colspan + `"><h3 id="` + htmlid + `">` + group.StringWithSize() + cancelLink + `</h3></td></tr>`)
+				}
+				res.WriteString(`<tr class="headings-middle assigned"><th><span class="spacer"></span></th><th>` + l["name"] + `</th>` + choiceHeadings + `</tr>`)
 				for _, person := range group.Members {
-					res.WriteString(`<tr class="person assigned"><td><!--input type="checkbox" name="person` + strconv.Itoa(i) + `"--></td><td>` + person.Name + `</td>`)
+					class := "person assigned"
+					if group.IsLocked(person) {
+						class += " locked"
+					}
+					if violated[person] != "" {
+						res.WriteString(`<tr class="` + class + ` violated" title="` + l["constraint_violated"] + "\n" + violated[person] + `">`)
+					} else {
+						res.WriteString(`<tr class="` + class + `">`)
+					}
+					res.WriteString(`<td><!--input type="checkbox" name="person` + strconv.Itoa(i) + `"--><a class="lock" onclick="astilectron.sendMessage('/?person` + strconv.Itoa(person.IndexIn(persons)) + `&lock=` + strconv.Itoa(i) + `&internalLink=#` + htmlid + `')" title="` + l["lock"] + `">&#128274;</a></td><td` + attributesTitle(person) + `>` + person.Name + friendsLabel(person, group) + `</td>`)
 
-					for j := 0; j < 3; j++ {
-						if j >= len(person.Preferences) {
+					for j := 0; j < nChoices; j++ {
+						var pref *matching.Group
+						if j < len(person.Preferences) {
+							pref = slotGroup(person.Preferences[j], group.Slot)
+						}
+						if pref == nil {
 							res.WriteString(`<td>--------</td>`)
 						} else {
-							pref := person.Preferences[j]
 							prefID := pref.IndexIn(groups)
 							personID := person.IndexIn(persons)
 							if pref == group {
@@ -584,7 +956,11 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 	// display editmode panel with texbox
 	if editmode {
 		res.WriteString(`<div class="panel">`)
-		res.WriteString(`<textarea id="edit" name="data" line="` + strconv.Itoa(errorLine) + `">` + editmodeContent + `</textarea>`)
+		lines := make([]string, len(errorLines))
+		for i, line := range errorLines {
+			lines[i] = strconv.Itoa(line)
+		}
+		res.WriteString(`<textarea id="edit" name="data" lines="` + strings.Join(lines, ",") + `">` + editmodeContent + `</textarea>`)
 		res.WriteString(`</div></form>`)
 	}
 
@@ -603,43 +979,182 @@ func handleChanges(form url.Values, data string, calledByForm bool) string {
 	return res.String()
 }
 
+// returns the select element for the objective of the current project
+func objectiveSelect() string {
+	res := bytes.Buffer{}
+	res.WriteString(`<select title="` + l["objective"] + `" onchange="astilectron.sendMessage('/?objective=' + this.value)">`)
+	for _, name := range []string{"sum", "rank_maximal", "leximin"} {
+		if name == options.Objective {
+			res.WriteString(`<option value="` + name + `" selected>` + l["objective_"+name] + `</option>`)
+		} else {
+			res.WriteString(`<option value="` + name + `">` + l["objective_"+name] + `</option>`)
+		}
+	}
+	res.WriteString(`</select>`)
+	return res.String()
+}
+
+// returns the input of the seed for the next matching, which is empty if every matching gets a new seed
+func seedInput() string {
+	value := ""
+	if options.Seed != 0 {
+		value = strconv.FormatInt(options.Seed, 10)
+	}
+	return `<input class="seed" type="text" title="` + l["seed"] + `" placeholder="` + l["seed_random"] + `" value="` + value + `" onchange="astilectron.sendMessage('/?seed=' + encodeURIComponent(this.value))">`
+}
+
+// match in the background by calling solve with the options of the project while showing the progress in the window
+// solve works on a copy of the current project, which replaces the project when it found an assignment, and returns
+// the text that is shown with the result
+// the result is added to the history as change of the project before by the given action
+func startMatching(action string, project *matching.Project, solve func(ctx context.Context, opts matching.Options) (string, error), before matching.JSONProject) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelMatching = cancel
+	opts := options
+	opts.Progress = func(p matching.Progress) {
+		sendProgress(p, opts)
+	}
+	go func() {
+		text, err := solve(ctx, opts)
+		cancel()
+
+		projectLock.Lock()
+		finishMatching(action, project, text, err, before)
+		projectLock.Unlock()
+
+		updateBody()
+	}()
+}
+
+// show the result of a matching and replace the current project by the matched copy if an assignment was found,
+// canceled and failed matchings leave the project and the history unchanged
+func finishMatching(action string, project *matching.Project, text string, err error, before matching.JSONProject) {
+	cancelMatching = nil
+	matchingMoves = text
+	if err != nil {
+		matchingErrors = l[err.Error()] + "<br>"
+		if err.Error() != "softtimeout" {
+			return
+		}
+	}
+	setProject(project)
+	hist.record(action, before)
+}
+
+// send the progress of the running matching to the progress bar
+func sendProgress(p matching.Progress, opts matching.Options) {
+	// until a solution is found the matching runs until the hard timeout, afterwards until the soft timeout
+	percent := 100 * p.Elapsed.Seconds() / opts.HardTimeout.Seconds()
+	if p.Found > 0 {
+		percent = 100 * p.Elapsed.Seconds() / opts.SoftTimeout.Seconds()
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	body, err := json.Marshal(struct {
+		Percent float64
+		Text    string
+	}{
+		percent,
+		fmt.Sprintf(l["progress"], p.Attempts, p.Found, p.BestQuote),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	w.SendMessage(Message{"progress", string(body)})
+}
+
 // handle file-uploads for import
 func handleImport(filepath string) (err error) {
-	file, err := os.Open(filepath)
+	project, err := loadProject(filepath)
 	if err != nil {
 		return
 	}
+	setProject(project)
+	hist = loadHistory(filepath, snapshot())
+	filename = filepath
+	return
+}
+
+// read the project from the given file
+func loadProject(filepath string) (*matching.Project, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
 
 	defer file.Close()
 
-	groups, persons, err = parseInput.ParseGroupsAndPersons(file)
-	filename = filepath
-	return
+	switch strings.ToLower(path.Ext(filepath)) {
+	case ".csv":
+		return loadCSV(file, filepath, csvMapping())
+	case ".xlsx":
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return parseInput.ParseExcel(file, info.Size())
+	case ".json":
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		return matching.ProjectFromJSON(data)
+	}
+	return parseInput.ParseProject(file)
+}
+
+// formats the project for the given file, .json files get the JSON project format and all others the .gm format
+func formatProjectFile(project *matching.Project, filepath string) ([]byte, error) {
+	if strings.ToLower(path.Ext(filepath)) == ".json" {
+		return matching.ProjectToJSON(project)
+	}
+	text, err := parseInput.FormatProject(project)
+	return []byte(text), err
+}
+
+//returns the column mapping for .csv files as exported by the program itself or by online forms
+func csvMapping() parseInput.CSVMapping {
+	mapping := parseInput.DefaultCSVMapping()
+	mapping.AssignedHeading = l["group_assigned"]
+	mapping.IgnoreHeadings = []string{l["cost"]}
+	return mapping
+}
+
+//reads persons from a .csv file, groups are read from "<name>.groups.csv" if that file exists
+func loadCSV(file io.Reader, filepath string, mapping parseInput.CSVMapping) (*matching.Project, error) {
+	groupsFile, err := os.Open(strings.TrimSuffix(filepath, path.Ext(filepath)) + ".groups.csv")
+	if err != nil {
+		return parseInput.ParseCSV(file, nil, mapping)
+	}
+	defer groupsFile.Close()
+	return parseInput.ParseCSV(file, groupsFile, mapping)
 }
 
 //handle save_as action
 func handleSaveAs(filepath string) (err error) {
-	defer updateBody()
-
 	file, err := os.Create(filepath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	text, err := parseInput.FormatGroupsAndPersons(groups, persons)
+	data, err := formatProjectFile(currentProject(), filepath)
 	if err != nil {
 		if err.Error() != "groups_empty" {
 			return err
 		}
 	}
-	_, err = file.WriteString(text)
-	return err
+	_, err = file.Write(data)
+	if err != nil {
+		return err
+	}
+	return hist.save(filepath)
 }
 
 //handle export as excel-file actions
 func handleExport(filepath string, total bool) (err error) {
-	file, err := parseInput.FormatGroupsAndPersonsToExcel(groups, persons, l, total)
+	file, err := parseInput.FormatGroupsAndPersonsToExcel(groups, persons, options.RankCosts, l, total)
 	if err != nil {
 		return err
 	}
@@ -671,6 +1186,12 @@ func main() {
 	flag.Parse()
 
 	initLangs()
+	options = defaultOptions()
+
+	// run without GUI if called with a command
+	if flag.NArg() >= 1 && isCommand(flag.Arg(0)) {
+		os.Exit(runCommand(flag.Args()))
+	}
 
 	// properly exit on receiving exit signal
 	go func() {
@@ -694,9 +1215,13 @@ func main() {
 	})))
 	http.HandleFunc("/", handleRoot)
 	http.HandleFunc("/about", handleAbout)
+	registerAPI(http.DefaultServeMux)
 
-	// listen on random free port
-	listener, err := net.Listen("tcp", "localhost:0")
+	// listen on random free port, the workspace has no token and is only available on localhost
+	if err := checkListenAddress(*listenFlag, ""); err != nil {
+		log.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", *listenFlag)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -812,17 +1337,17 @@ func main() {
 					}},
 					{Label: astikit.StrPtr(l["save"]), OnClick: func(e astilectron.Event) bool {
 
-						if projectPath != "" {
-							err := handleSaveAs(projectPath)
-							if err == nil {
-								form, err := url.ParseQuery("save")
-								if err != nil {
-									log.Fatal(err)
-								}
-								body := handleChanges(form, "", false)
-								sendBody(body)
-								return false
+						projectLock.Lock()
+						saved := projectPath != "" && handleSaveAs(projectPath) == nil
+						projectLock.Unlock()
+						if saved {
+							form, err := url.ParseQuery("save")
+							if err != nil {
+								log.Fatal(err)
 							}
+							body := handleChanges(form, "", false)
+							sendBody(body)
+							return false
 						}
 
 						w.SendMessage(struct {
@@ -853,6 +1378,29 @@ func main() {
 					{Label: astikit.StrPtr(l["exit"]), Role: astilectron.MenuItemRoleQuit},
 				},
 			},
+			{
+				Label: astikit.StrPtr(l["history"]),
+				SubMenu: []*astilectron.MenuItemOptions{
+					{Label: astikit.StrPtr(l["undo"]), OnClick: func(e astilectron.Event) bool {
+						form, err := url.ParseQuery("undo")
+						if err != nil {
+							log.Fatal(err)
+						}
+						body := handleChanges(form, "", false)
+						sendBody(body)
+						return false
+					}},
+					{Label: astikit.StrPtr(l["redo"]), OnClick: func(e astilectron.Event) bool {
+						form, err := url.ParseQuery("redo")
+						if err != nil {
+							log.Fatal(err)
+						}
+						body := handleChanges(form, "", false)
+						sendBody(body)
+						return false
+					}},
+				},
+			},
 			{
 				Label: astikit.StrPtr(l["language"]),
 				SubMenu: func() []*astilectron.MenuItemOptions {
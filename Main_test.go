@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckErrorText(t *testing.T) {
+	l = map[string]string{
+		"combination_overfilled":   "too many persons: ",
+		"constraint_contradiction": "contradicting constraints: ",
+		"constraint_unsatisfiable": "constraints can't be fulfilled: ",
+		"balance_unsatisfiable":    "balance rule can't be fulfilled: ",
+	}
+	tests := []struct {
+		key     string
+		details string
+		want    string
+	}{
+		{"constraint_contradiction", "Anna, Ben", "contradicting constraints: Anna, Ben"},
+		{"constraint_unsatisfiable", "Anna, Ben, Cleo", "constraints can't be fulfilled: Anna, Ben, Cleo"},
+		{"balance_unsatisfiable", "A: role=lead", "balance rule can't be fulfilled: A: role=lead"},
+	}
+	for _, test := range tests {
+		if got := checkErrorText(errors.New(test.key), test.details); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.key, got, test.want)
+		}
+	}
+}
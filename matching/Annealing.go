@@ -0,0 +1,197 @@
+package matching
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// solver that improves a single assignment by simulated annealing with a short tabu list, which works for all
+// constraints, balance rules and friends as it only rates the states by their violations and costs
+type annealingSolver struct{}
+
+func (annealingSolver) Name() string {
+	return "annealing"
+}
+
+// the temperature at the end of the cooling schedule
+const minTemperature = 0.01
+
+// number of steps at the start temperature at the beginning of every schedule, in which the length of the schedule is
+// measured if it isn't given
+const calibrationSteps = 1000
+
+// Starts with every person in its first wish and makes random changes (see search.changes). Changes that lower the
+// penalty are always made, others with a probability that sinks with the temperature, which is lowered from
+// opts.StartTemperature by opts.Cooling within opts.AnnealSteps steps. Without them, the number of steps that fit into
+// opts.AnnealTime is measured in the first steps and returned in the stats, so the run doesn't depend on the clock
+// and can be repeated. A violation is penalized higher than any cost of a single person, so feasible states are
+// always preferred. Persons that were just moved can't be moved again for some steps unless the change leads to the
+// best state so far.
+// If no feasible state was found at the end of the schedule, it starts again from the best state until the hard
+// timeout. Like the other solvers, the search stops after the soft timeout if a feasible state was found.
+func (annealingSolver) Solve(ctx context.Context, m *Matcher, opts Options) (Assignment, Stats, error) {
+	if ctx.Err() != nil {
+		return nil, Stats{}, errors.New("canceled")
+	}
+	start := time.Now()
+	hardCtx, cancel := context.WithTimeout(ctx, opts.HardTimeout)
+	defer cancel()
+
+	rnd := newRand(opts.Seed)
+	s := m.newSearch(nil, opts.RankCosts)
+	if len(s.movable) == 0 {
+		return s.assignment(), Stats{}, nil
+	}
+	weight := s.violationWeight()
+	violations, cost := s.rate()
+	energy := weight*violations + cost
+	best, bestViolations, bestEnergy := s.assignment(), violations, energy
+	tabu := make(map[*Person]int)
+	tenure := 1 + len(s.movable)/10
+
+	steps, found := 0, 0
+	if violations == 0 {
+		found++
+	}
+	report := func() {
+		if opts.Progress != nil {
+			var quote float64
+			if bestViolations == 0 {
+				quote = best.quote(opts.RankCosts)
+			}
+			opts.Progress(Progress{Attempts: steps, Found: found, BestQuote: quote, Elapsed: time.Since(start)})
+		}
+	}
+
+	var err error
+	total, scheduleStart, lastReport := opts.AnnealSteps, 0, start
+	for {
+		// the clock is only read every few steps as the steps are cheap, it only decides when to stop
+		if steps%64 == 0 {
+			now := time.Now()
+			if hardCtx.Err() != nil {
+				break
+			}
+			if bestViolations == 0 && now.Sub(start) > opts.SoftTimeout {
+				err = errors.New("softtimeout")
+				break
+			}
+			if now.Sub(lastReport) > progressInterval {
+				report()
+				lastReport = now
+			}
+		}
+		if total == 0 && steps == calibrationSteps {
+			total = int(calibrationSteps * opts.AnnealTime.Seconds() / time.Since(start).Seconds())
+			if total < calibrationSteps {
+				total = calibrationSteps
+			}
+		}
+		if total > 0 && steps-scheduleStart >= total {
+			if bestViolations == 0 {
+				break
+			}
+			// reheat at the best state
+			for p, g := range best {
+				s.apply(change{{p, g}})
+			}
+			violations, cost = s.rate()
+			energy = weight*violations + cost
+			scheduleStart = steps
+		}
+		steps++
+
+		p := s.movable[rnd.Intn(len(s.movable))]
+		changes := s.changes(p)
+		if len(changes) == 0 {
+			continue
+		}
+		c := changes[rnd.Intn(len(changes))]
+		dv, dc := s.delta(c)
+		de := weight*dv + dc
+		if energy+de >= bestEnergy {
+			isTabu := false
+			for _, mv := range c {
+				if tabu[mv.p] > steps {
+					isTabu = true
+				}
+			}
+			if isTabu {
+				continue
+			}
+		}
+		if de > 0 && rnd.Float64() >= math.Exp(-float64(de)/opts.temperature(steps-scheduleStart, total)) {
+			continue
+		}
+
+		s.apply(c)
+		violations, cost, energy = violations+dv, cost+dc, energy+de
+		for _, mv := range c {
+			tabu[mv.p] = steps + tenure
+		}
+		if energy < bestEnergy {
+			best, bestViolations, bestEnergy = s.assignment(), violations, energy
+			if violations == 0 {
+				found++
+			}
+		}
+	}
+	report()
+
+	stats := Stats{Attempts: steps, AnnealSteps: total}
+	if bestViolations > 0 {
+		if ctx.Err() == context.Canceled {
+			return nil, stats, errors.New("canceled")
+		}
+		return nil, stats, errors.New("hardtimeout")
+	}
+	if ctx.Err() == context.Canceled {
+		return best, stats, errors.New("canceled")
+	}
+	// the random changes rarely hit the last improvements, so they are made greedily
+	for p, g := range best {
+		s.apply(change{{p, g}})
+	}
+	s.improve(hardCtx, 20*len(s.movable)+100, s.movable, false)
+	return s.assignment(), stats, err
+}
+
+func init() {
+	RegisterSolver(annealingSolver{})
+}
+
+// returns the penalty of a violation, which is higher than the costs of any single change of a person
+func (s *search) violationWeight() int {
+	weight := 0
+	for _, p := range s.movable {
+		for _, g := range s.allowed[p] {
+			if c := s.rc.Cost(p, g); c > weight {
+				weight = c
+			}
+		}
+		if f := s.rc.FriendCost() * len(s.friendsOf[p]); f > weight {
+			weight = f
+		}
+	}
+	return 2*weight + 1
+}
+
+// returns the temperature of the cooling schedule with the given total number of steps after the given steps
+func (o *Options) temperature(steps, total int) float64 {
+	if steps < calibrationSteps {
+		return o.StartTemperature
+	}
+	f := float64(steps) / float64(total)
+	if f > 1 {
+		f = 1
+	}
+	t := o.StartTemperature
+	if o.Cooling == "linear" {
+		t *= 1 - f
+	} else {
+		t *= math.Pow(minTemperature/o.StartTemperature, f)
+	}
+	return math.Max(t, minTemperature)
+}
@@ -0,0 +1,312 @@
+package matching
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BalanceRule limits how many persons with an attribute can be in a group, e.g. "at most 60% of one class" or
+// "at least 2 of level=advanced". Empty groups don't need to fulfill any rule.
+type BalanceRule struct {
+	// the group the rule applies to, nil for every group
+	Group *Group
+	// the attribute, if the value is empty the rule applies to every value of the key separately
+	Key, Value string
+	// whether Amount is the minimum or the maximum
+	AtLeast bool
+	// number of persons or percentage of the members of the group
+	Amount  int
+	Percent bool
+}
+
+// parses a balance rule in the syntax "min|max;key[=value];amount[%][;group]"
+func ParseBalanceRule(str string, groups []*Group) (*BalanceRule, error) {
+	params := strings.Split(str, ";")
+	if params[0] != "min" && params[0] != "max" {
+		return nil, errors.New("syntax_error")
+	}
+	if len(params) < 3 {
+		return nil, errors.New("missing_argument")
+	}
+	if len(params) > 4 {
+		return nil, errors.New("syntax_error")
+	}
+	attribute := strings.SplitN(params[1], "=", 2)
+	key, value := strings.TrimSpace(attribute[0]), ""
+	if len(attribute) == 2 {
+		value = strings.TrimSpace(attribute[1])
+		if value == "" {
+			return nil, errors.New("empty_argument")
+		}
+	}
+	group := ""
+	if len(params) == 4 {
+		group = params[3]
+	}
+	return ParseBalanceFields(params[0], key, value, params[2], group, groups)
+}
+
+// parses a balance rule from its fields that are already separated, e.g. by a file format that allows quoting
+// an empty value applies the rule to every value of the key, an empty group to every group
+func ParseBalanceFields(kind, key, value, amount, group string, groups []*Group) (*BalanceRule, error) {
+	if kind != "min" && kind != "max" {
+		return nil, errors.New("syntax_error")
+	}
+	r := &BalanceRule{AtLeast: kind == "min", Key: key, Value: value}
+	if r.Key == "" {
+		return nil, errors.New("empty_argument")
+	}
+	amount = strings.TrimSpace(amount)
+	if strings.HasSuffix(amount, "%") {
+		r.Percent = true
+		amount = strings.TrimSuffix(amount, "%")
+	}
+	var err error
+	r.Amount, err = strconv.Atoi(amount)
+	if err != nil || r.Amount < 0 || (r.Percent && r.Amount > 100) {
+		return nil, errors.New("syntax_error")
+	}
+	if group != "" {
+		r.Group = FindGroup(group, groups)
+		if r.Group == nil {
+			return nil, errors.New("group_not_found")
+		}
+	}
+	return r, nil
+}
+
+// returns the rule in the syntax of ParseBalanceRule
+func (r *BalanceRule) String() string {
+	s := "max"
+	if r.AtLeast {
+		s = "min"
+	}
+	s += ";" + r.Key
+	if r.Value != "" {
+		s += "=" + r.Value
+	}
+	s += ";" + strconv.Itoa(r.Amount)
+	if r.Percent {
+		s += "%"
+	}
+	if r.Group != nil {
+		s += ";" + r.Group.Name
+	}
+	return s
+}
+
+// returns the value of the attribute of the person, ok is false if the person doesn't count for the rule
+func (r *BalanceRule) valueOf(p *Person) (value string, ok bool) {
+	value, ok = p.Attributes[r.Key]
+	if ok && r.Value != "" && value != r.Value {
+		return "", false
+	}
+	return
+}
+
+// returns how many persons with the value a group with the given number of members may have at most or needs at least
+func (r *BalanceRule) bound(size int) int {
+	if !r.Percent {
+		return r.Amount
+	}
+	if r.AtLeast {
+		return (r.Amount*size + 99) / 100
+	}
+	return r.Amount * size / 100
+}
+
+// returns by how many persons the rule is violated in a group with the given number of members of which count have the value
+func (r *BalanceRule) violation(count, size int) int {
+	if size == 0 {
+		return 0
+	}
+	b := r.bound(size)
+	if r.AtLeast && count < b {
+		return b - count
+	}
+	if !r.AtLeast && count > b {
+		return count - b
+	}
+	return 0
+}
+
+// a balance rule applied to the persons and groups of a matcher
+type balance struct {
+	*BalanceRule
+	persons []*Person
+	groups  []*Group
+	values  []string
+	// indices of the groups and of the value of every person, -1 if it has none
+	groupIndex map[*Group]int
+	valueIndex []int
+}
+
+func newBalance(r *BalanceRule, persons []*Person, groups []*Group) *balance {
+	b := &balance{BalanceRule: r, persons: persons, groups: groups, groupIndex: make(map[*Group]int), valueIndex: make([]int, len(persons))}
+	if r.Group != nil {
+		b.groups = []*Group{r.Group}
+	}
+	for i, g := range b.groups {
+		b.groupIndex[g] = i
+	}
+	seen := make(map[string]bool)
+	for _, p := range persons {
+		if v, ok := r.valueOf(p); ok && !seen[v] {
+			seen[v] = true
+			b.values = append(b.values, v)
+		}
+	}
+	if r.Value != "" {
+		b.values = []string{r.Value}
+	}
+	sort.Strings(b.values)
+	for i, p := range persons {
+		b.valueIndex[i] = -1
+		if v, ok := r.valueOf(p); ok {
+			b.valueIndex[i] = sort.SearchStrings(b.values, v)
+		}
+	}
+	return b
+}
+
+// every person changes the size of a group
+func (b *balance) affected() []*Person {
+	return b.persons
+}
+
+// counts the members of the groups of the rule and how many of them have every value, count[i][j] belongs to the
+// group with index i and the value with index j
+func (b *balance) count(groupOf func(*Person) *Group) (size []int, count [][]int) {
+	size = make([]int, len(b.groups))
+	count = make([][]int, len(b.groups))
+	for i := range count {
+		count[i] = make([]int, len(b.values))
+	}
+	for k, p := range b.persons {
+		g := groupOf(p)
+		if g == nil {
+			continue
+		}
+		i, ok := b.groupIndex[g]
+		if !ok {
+			continue
+		}
+		size[i]++
+		if j := b.valueIndex[k]; j != -1 {
+			count[i][j]++
+		}
+	}
+	return
+}
+
+func (b *balance) violations(groupOf func(*Person) *Group) int {
+	size, count := b.count(groupOf)
+	n := 0
+	for i := range b.groups {
+		for j := range b.values {
+			n += b.violation(count[i][j], size[i])
+		}
+	}
+	return n
+}
+
+func (b *balance) branch(groupOf func(*Person) *Group, fixed func(*Person) bool) (*Person, *Group) {
+	size, count := b.count(groupOf)
+	for i, g := range b.groups {
+		for j := range b.values {
+			if b.violation(count[i][j], size[i]) == 0 {
+				continue
+			}
+			// persons with the value can join or leave the group, others change the percentage or empty the group
+			var other *Person
+			for k, p := range b.persons {
+				h := groupOf(p)
+				if h == nil || fixed(p) {
+					continue
+				}
+				has := b.valueIndex[k] == j
+				if b.AtLeast && has && h != g || !b.AtLeast && has && h == g {
+					return p, h
+				}
+				if other == nil && (h == g || !b.AtLeast && b.Percent && !has) {
+					other = p
+				}
+			}
+			if other == nil {
+				return nil, nil
+			}
+			return other, groupOf(other)
+		}
+	}
+	return nil, nil
+}
+
+// returns the violated rules of every group
+func BalanceViolations(rules []*BalanceRule, groups []*Group, persons []*Person) map[*Group][]*BalanceRule {
+	violated := make(map[*Group][]*BalanceRule)
+	for _, r := range rules {
+		b := newBalance(r, persons, groups)
+		// with slots, only the groups of the slot are counted for each of them
+		for _, groupOf := range slotGroupOfs(groups) {
+			size, count := b.count(groupOf)
+			for i, g := range b.groups {
+				for j := range b.values {
+					if b.violation(count[i][j], size[i]) > 0 {
+						violated[g] = append(violated[g], r)
+						break
+					}
+				}
+			}
+		}
+	}
+	return violated
+}
+
+// returns all persons of the matcher including the members of its groups, members of several slots only once
+func (m *Matcher) allPersons() []*Person {
+	persons := GetGrouplessPersons(m.Persons, m.Groups)
+	seen := make(map[*Person]bool)
+	for _, g := range m.Groups {
+		for _, p := range g.Members {
+			if !seen[p] {
+				seen[p] = true
+				persons = append(persons, p)
+			}
+		}
+	}
+	return persons
+}
+
+// checks whether there are enough persons for the minimums and enough places for the maximums of the balance rules
+func (m *Matcher) checkBalance() (error, string) {
+	persons := m.allPersons()
+	for _, r := range m.Balance {
+		b := newBalance(r, persons, m.Groups)
+		for j := range b.values {
+			n := 0
+			for _, k := range b.valueIndex {
+				if k == j {
+					n++
+				}
+			}
+			// groups that can't be empty need the minimum, the maximum limits the places of the groups of the rule
+			limit := 0
+			for _, g := range m.Groups {
+				_, inRule := b.groupIndex[g]
+				if r.AtLeast && inRule && g.MinSize > 0 {
+					limit += r.bound(g.MinSize)
+				} else if !r.AtLeast && inRule && r.bound(g.Capacity) < g.Capacity {
+					limit += r.bound(g.Capacity)
+				} else if !r.AtLeast {
+					limit += g.Capacity
+				}
+			}
+			if r.AtLeast && n < limit || !r.AtLeast && n > limit {
+				return errors.New("balance_unsatisfiable"), r.String()
+			}
+		}
+	}
+	return nil, ""
+}
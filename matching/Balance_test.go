@@ -0,0 +1,141 @@
+package matching
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func TestBalanceRespected(t *testing.T) {
+	for _, solver := range SolverNames() {
+		groups, persons := newTestProject([][2]int{{4, 2}, {4, 2}, {4, 0}},
+			[][]int{{0, 1}, {0, 1}, {0, 1}, {0, 2}, {1, 2}, {0, 1}, {0, 2}, {1, 0}})
+		for i, p := range persons {
+			p.Attributes = map[string]string{"class": []string{"x", "y"}[i%2]}
+			if i < 3 {
+				p.Attributes["level"] = "advanced"
+			}
+		}
+		m := NewMatcher(persons, groups)
+		var err error
+		m.Balance = make([]*BalanceRule, 2)
+		m.Balance[0], err = ParseBalanceRule("max;class;50%", groups)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Balance[1], err = ParseBalanceRule("min;level=advanced;1", groups)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := m.Solve(context.Background(), testOptions(solver)); err != nil {
+			t.Errorf("%s: %v", solver, err)
+			continue
+		}
+		if violated := BalanceViolations(m.Balance, groups, persons); len(violated) > 0 {
+			for g, rules := range violated {
+				t.Errorf("%s: group %s violates %v", solver, g.Name, rules)
+			}
+		}
+		if groupless := GetGrouplessPersons(persons, groups); len(groupless) > 0 {
+			t.Errorf("%s: %v are groupless", solver, groupless)
+		}
+	}
+}
+
+func TestCheckBalance(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []string
+		// the error of CheckMatcher, empty if the check passes
+		want string
+		// the error of the exact solver for rules that pass the check
+		solve string
+	}{
+		{
+			name:  "feasible",
+			rules: []string{"max;class;50%", "min;level=advanced;1"},
+		},
+		{
+			name:  "too few persons for the minimum of every group",
+			rules: []string{"min;level=advanced;2"},
+			want:  "balance_unsatisfiable",
+		},
+		{
+			name:  "too many persons for the maximum of every group",
+			rules: []string{"max;class=x;1"},
+			want:  "balance_unsatisfiable",
+		},
+		{
+			name:  "minimum of one group",
+			rules: []string{"min;class=y;3;A"},
+		},
+		{
+			name:  "contradicting rules",
+			rules: []string{"min;class=y;3;A", "max;class;1;A"},
+			solve: "no_solution",
+		},
+	}
+
+	for _, test := range tests {
+		groups, persons := newTestProject([][2]int{{4, 2}, {4, 2}, {4, 0}},
+			[][]int{{0, 1}, {0, 1}, {0, 1}, {0, 2}, {1, 2}, {0, 1}, {0, 2}, {1, 0}})
+		for i, p := range persons {
+			p.Attributes = map[string]string{"class": []string{"x", "y"}[i%2]}
+			if i < 3 {
+				p.Attributes["level"] = "advanced"
+			}
+		}
+		m := NewMatcher(persons, groups)
+		for _, s := range test.rules {
+			r, err := ParseBalanceRule(s, groups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.Balance = append(m.Balance, r)
+		}
+
+		err, rule := m.CheckMatcher()
+		if test.want != "" {
+			if err == nil || err.Error() != test.want || rule != test.rules[0] {
+				t.Errorf("%s: CheckMatcher returns %v: %s, want %s: %s", test.name, err, rule, test.want, test.rules[0])
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v: %s", test.name, err, rule)
+			continue
+		}
+		_, err = m.Solve(context.Background(), testOptions("exact"))
+		if test.solve == "" && err != nil || test.solve != "" && (err == nil || err.Error() != test.solve) {
+			t.Errorf("%s: the exact solver returns %v, want %q", test.name, err, test.solve)
+		}
+	}
+}
+
+func TestBalanceRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	checkRandomProjects(t, rnd, func(m *Matcher) {
+		for _, p := range m.Persons {
+			p.Attributes = map[string]string{"class": []string{"x", "y"}[rnd.Intn(2)]}
+			if rnd.Intn(2) == 0 {
+				p.Attributes["level"] = "advanced"
+			}
+		}
+		for k := rnd.Intn(3); k > 0; k-- {
+			r := &BalanceRule{AtLeast: rnd.Intn(2) == 0, Key: "class"}
+			if rnd.Intn(2) == 0 {
+				r.Key, r.Value = "level", "advanced"
+			}
+			if rnd.Intn(2) == 0 {
+				r.Percent, r.Amount = true, 30+rnd.Intn(50)
+			} else {
+				r.Amount = rnd.Intn(3)
+			}
+			if rnd.Intn(3) == 0 {
+				r.Group = m.Groups[rnd.Intn(len(m.Groups))]
+			}
+			m.Balance = append(m.Balance, r)
+		}
+	})
+}
@@ -0,0 +1,231 @@
+package matching
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// kinds of constraints
+const (
+	Together = "together"
+	Apart    = "apart"
+)
+
+// Constraint forces its persons to be in the same group (Together) or in pairwise different groups (Apart)
+type Constraint struct {
+	Kind    string
+	Persons []*Person
+}
+
+func NewConstraint(kind string, persons []*Person) *Constraint {
+	return &Constraint{Kind: kind, Persons: persons}
+}
+
+func (c *Constraint) String() string {
+	return c.Kind + ": " + fmt.Sprint(c.Persons)
+}
+
+// returns the names of the persons of the constraint separated by commas
+func (c *Constraint) names() string {
+	names := make([]string, len(c.Persons))
+	for i, p := range c.Persons {
+		names[i] = p.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// a hard rule for the assignment of persons, solvers only return assignments that don't violate any rule
+type rule interface {
+	// the persons whose groups influence the rule
+	affected() []*Person
+	// how strongly the rule is violated if every person p is in groupOf(p) (nil if unassigned), 0 if it is satisfied
+	violations(groupOf func(*Person) *Group) int
+	// returns a person that isn't fixed yet together with its group, so that deciding whether the person stays in the
+	// group helps to resolve a violation, nil if the violation can't be resolved anymore
+	branch(groupOf func(*Person) *Group, fixed func(*Person) bool) (*Person, *Group)
+}
+
+func (c *Constraint) affected() []*Person {
+	return c.Persons
+}
+
+// Together: the number of assigned persons that are not in the most common group
+// Apart: the number of pairs of persons in the same group
+func (c *Constraint) violations(groupOf func(*Person) *Group) int {
+	count := make(map[*Group]int)
+	assigned := 0
+	for _, p := range c.Persons {
+		if g := groupOf(p); g != nil {
+			count[g]++
+			assigned++
+		}
+	}
+	n := 0
+	for _, k := range count {
+		if c.Kind == Together && k > n {
+			n = k
+		} else if c.Kind == Apart {
+			n += k * (k - 1) / 2
+		}
+	}
+	if c.Kind == Together {
+		return assigned - n
+	}
+	return n
+}
+
+func (c *Constraint) branch(groupOf func(*Person) *Group, fixed func(*Person) bool) (*Person, *Group) {
+	if c.Kind == Together {
+		// all persons should join a fixed person, otherwise the most common group
+		target := c.commonGroup(groupOf, fixed)
+		var stay *Person
+		for _, p := range c.Persons {
+			if g := groupOf(p); g != nil && !fixed(p) {
+				if g != target {
+					return p, g
+				}
+				stay = p
+			}
+		}
+		if stay == nil {
+			return nil, nil
+		}
+		return stay, target
+	}
+	for i, p := range c.Persons {
+		for _, q := range c.Persons[i+1:] {
+			if g := groupOf(p); g != nil && g == groupOf(q) {
+				if !fixed(p) {
+					return p, g
+				}
+				if !fixed(q) {
+					return q, g
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// returns the group of a fixed person or the group most persons are in
+func (c *Constraint) commonGroup(groupOf func(*Person) *Group, fixed func(*Person) bool) *Group {
+	count := make(map[*Group]int)
+	var common *Group
+	for _, p := range c.Persons {
+		g := groupOf(p)
+		if g == nil {
+			continue
+		}
+		if fixed(p) {
+			return g
+		}
+		count[g]++
+		if common == nil || count[g] > count[common] {
+			common = g
+		}
+	}
+	return common
+}
+
+// returns all constraints that are violated by the current members of the groups, with slots in any slot
+func ViolatedConstraints(constraints []*Constraint, groups []*Group) []*Constraint {
+	groupOfs := slotGroupOfs(groups)
+	var violated []*Constraint
+	for _, c := range constraints {
+		for _, groupOf := range groupOfs {
+			if c.violations(groupOf) > 0 {
+				violated = append(violated, c)
+				break
+			}
+		}
+	}
+	return violated
+}
+
+// returns the rules all solvers have to respect
+func (m *Matcher) rules() []rule {
+	rules := make([]rule, 0, len(m.Constraints)+len(m.Balance)+len(m.distinct))
+	for _, c := range m.Constraints {
+		rules = append(rules, c)
+	}
+	for _, d := range m.distinct {
+		rules = append(rules, d)
+	}
+	if len(m.Balance) > 0 {
+		persons := m.allPersons()
+		for _, r := range m.Balance {
+			rules = append(rules, newBalance(r, persons, m.Groups))
+		}
+	}
+	return rules
+}
+
+// checks the constraints for contradictions and whether there are groups that can hold persons that have to be together
+func (m *Matcher) checkConstraints() (error, string) {
+	// persons that are transitively together
+	component := make(map[*Person]*Person)
+	var find func(p *Person) *Person
+	find = func(p *Person) *Person {
+		if component[p] == nil || component[p] == p {
+			return p
+		}
+		component[p] = find(component[p])
+		return component[p]
+	}
+	for _, c := range m.Constraints {
+		if c.Kind == Together {
+			for _, p := range c.Persons[1:] {
+				component[find(p)] = find(c.Persons[0])
+			}
+		}
+	}
+
+	for _, c := range m.Constraints {
+		if c.Kind != Apart {
+			continue
+		}
+		if len(c.Persons) > len(m.Groups) {
+			return errors.New("constraint_unsatisfiable"), c.names()
+		}
+		for i, p := range c.Persons {
+			for _, q := range c.Persons[i+1:] {
+				if find(p) == find(q) {
+					return errors.New("constraint_contradiction"), p.Name + ", " + q.Name
+				}
+			}
+		}
+	}
+
+	// the persons of every component need a common preference with enough space
+	members := make(map[*Person][]*Person)
+	for _, p := range m.Persons {
+		members[find(p)] = append(members[find(p)], p)
+	}
+	for _, c := range m.Constraints {
+		if c.Kind != Together {
+			continue
+		}
+		persons := members[find(c.Persons[0])]
+		fits := false
+		for _, g := range m.Groups {
+			if len(persons) <= g.Capacity-len(g.Members) && commonPreference(persons, g) {
+				fits = true
+			}
+		}
+		if len(persons) > 0 && !fits {
+			return errors.New("constraint_unsatisfiable"), c.names()
+		}
+	}
+	return nil, ""
+}
+
+// checks whether all persons wish for the given group
+func commonPreference(persons []*Person, g *Group) bool {
+	for _, p := range persons {
+		if g.IndexIn(p.Preferences) == -1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,222 @@
+package matching
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// returns the options of a short and reproducible run of the solver
+func testOptions(solver string) Options {
+	opts := DefaultOptions()
+	opts.Solver, opts.Seed, opts.Tries, opts.AnnealSteps = solver, 1, 10, 20000
+	opts.SoftTimeout, opts.HardTimeout = time.Second, 5*time.Second
+	return opts
+}
+
+// whether the assignment of the groupless persons respects all group sizes and rules of the matcher
+func (m *Matcher) valid(a Assignment) bool {
+	s := m.newSearch(a, m.RankCosts)
+	if len(s.movable) != len(a) {
+		return false
+	}
+	violations, _ := s.rate()
+	return violations == 0
+}
+
+// returns the minimal rank costs of an assignment of all persons that respects the group sizes and rules by trying
+// every assignment, -1 if there is none
+func bruteForce(m *Matcher) int {
+	rules := m.rules()
+	best := -1
+	groupOf := make(map[*Person]*Group)
+	var assign func(i, cost int)
+	assign = func(i, cost int) {
+		if i < len(m.Persons) {
+			p := m.Persons[i]
+			for _, g := range p.Preferences {
+				groupOf[p] = g
+				assign(i+1, cost+m.RankCosts.Cost(p, g))
+			}
+			return
+		}
+		size := make(map[*Group]int)
+		for _, g := range groupOf {
+			size[g]++
+		}
+		for _, g := range m.Groups {
+			if size[g] < g.MinSize || size[g] > g.Capacity {
+				return
+			}
+		}
+		for _, r := range rules {
+			if r.violations(func(p *Person) *Group { return groupOf[p] }) > 0 {
+				return
+			}
+		}
+		if best == -1 || cost < best {
+			best = cost
+		}
+	}
+	assign(0, 0)
+	return best
+}
+
+// returns a small random project in which every person wishes for some groups
+func randomTestProject(rnd *rand.Rand) ([]*Group, []*Person) {
+	n := 2 + rnd.Intn(3)
+	var sizes [][2]int
+	for i := 0; i < n; i++ {
+		min := rnd.Intn(2)
+		sizes = append(sizes, [2]int{min + 1 + rnd.Intn(3), min})
+	}
+	var wishes [][]int
+	for i := 2 + rnd.Intn(6); i > 0; i-- {
+		wishes = append(wishes, rnd.Perm(n)[:1+rnd.Intn(n)])
+	}
+	return newTestProject(sizes, wishes)
+}
+
+// solves the random projects with the exact and the heuristic solver and compares them with the brute force
+func checkRandomProjects(t *testing.T, rnd *rand.Rand, rules func(m *Matcher)) {
+	for it := 0; it < 100; it++ {
+		groups, persons := randomTestProject(rnd)
+		m := NewMatcher(persons, groups)
+		rules(m)
+		want := bruteForce(m)
+
+		a, _, err := m.optimalAssignment(context.Background(), 0, newRand(1), nil)
+		if want == -1 && err == nil {
+			t.Fatalf("project %d: the exact solver found a solution of an infeasible project", it)
+		}
+		if want != -1 && (err != nil || !m.valid(a) || a.cost(m.RankCosts) != want) {
+			t.Fatalf("project %d: the exact solver returns costs %d and %v, want %d", it, a.cost(m.RankCosts), err, want)
+		}
+
+		opts := testOptions("heuristic")
+		opts.SoftTimeout, opts.HardTimeout = 10*time.Millisecond, 30*time.Millisecond
+		a, _, _ = heuristicSolver{}.Solve(context.Background(), m, opts)
+		if a != nil && (want == -1 || !m.valid(a) || a.cost(m.RankCosts) < want) {
+			t.Fatalf("project %d: the heuristic returns an invalid assignment", it)
+		}
+	}
+}
+
+func TestConstraintsRespected(t *testing.T) {
+	for _, solver := range SolverNames() {
+		groups, persons := newTestProject([][2]int{{2, 0}, {3, 0}, {3, 0}},
+			[][]int{{0, 1}, {0, 1}, {1, 0}, {2, 1}, {2, 1}, {2, 0}})
+		a, b, c, d, e, f := persons[0], persons[1], persons[2], persons[3], persons[4], persons[5]
+		m := NewMatcher(persons, groups)
+		m.Constraints = []*Constraint{
+			NewConstraint(Together, []*Person{a, c}),
+			NewConstraint(Apart, []*Person{d, e, f}),
+			NewConstraint(Apart, []*Person{a, b}),
+		}
+		if _, err := m.Solve(context.Background(), testOptions(solver)); err != nil {
+			t.Errorf("%s: %v", solver, err)
+			continue
+		}
+		if violated := ViolatedConstraints(m.Constraints, groups); len(violated) > 0 {
+			t.Errorf("%s: violated constraints %v", solver, violated)
+		}
+		if groupless := GetGrouplessPersons(persons, groups); len(groupless) > 0 {
+			t.Errorf("%s: %v are groupless", solver, groupless)
+		}
+		for _, g := range groups {
+			if len(g.Members) < g.MinSize || len(g.Members) > g.Capacity {
+				t.Errorf("%s: group %s has %d members", solver, g.Name, len(g.Members))
+			}
+		}
+	}
+}
+
+func TestCheckConstraints(t *testing.T) {
+	tests := []struct {
+		name string
+		// kinds and persons of the constraints as indices
+		constraints []string
+		persons     [][]int
+		want        string
+		names       string
+	}{
+		{
+			name:        "feasible",
+			constraints: []string{Together, Apart},
+			persons:     [][]int{{0, 1}, {0, 2}},
+		},
+		{
+			name:        "apart persons that are together",
+			constraints: []string{Together, Together, Apart},
+			persons:     [][]int{{0, 1}, {1, 2}, {2, 3, 0}},
+			want:        "constraint_contradiction",
+			names:       "c, a",
+		},
+		{
+			name:        "more persons apart than groups",
+			constraints: []string{Apart},
+			persons:     [][]int{{0, 1, 2, 3}},
+			want:        "constraint_unsatisfiable",
+			names:       "a, b, c, d",
+		},
+		{
+			name:        "together without common wish",
+			constraints: []string{Together},
+			persons:     [][]int{{0, 3}},
+			want:        "constraint_unsatisfiable",
+			names:       "a, d",
+		},
+		{
+			name:        "together in no group with enough places",
+			constraints: []string{Together, Together},
+			persons:     [][]int{{0, 1}, {1, 2}},
+			want:        "constraint_unsatisfiable",
+			names:       "a, b",
+		},
+	}
+
+	for _, test := range tests {
+		groups, persons := newTestProject([][2]int{{2, 0}, {2, 0}, {3, 0}},
+			[][]int{{0, 1}, {0, 1}, {1, 0}, {2}})
+		m := NewMatcher(persons, groups)
+		for i, kind := range test.constraints {
+			var ps []*Person
+			for _, k := range test.persons[i] {
+				ps = append(ps, persons[k])
+			}
+			m.Constraints = append(m.Constraints, NewConstraint(kind, ps))
+		}
+		err, names := m.CheckMatcher()
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v: %s", test.name, err, names)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.want || names != test.names {
+			t.Errorf("%s: CheckMatcher returns %v: %s, want %s: %s", test.name, err, names, test.want, test.names)
+		}
+	}
+}
+
+func TestConstraintsRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	checkRandomProjects(t, rnd, func(m *Matcher) {
+		for k := rnd.Intn(3); k > 0; k-- {
+			kind := Together
+			if rnd.Intn(2) == 0 {
+				kind = Apart
+			}
+			n := 2 + rnd.Intn(2)
+			if n > len(m.Persons) {
+				n = len(m.Persons)
+			}
+			var ps []*Person
+			for _, i := range rnd.Perm(len(m.Persons))[:n] {
+				ps = append(ps, m.Persons[i])
+			}
+			m.Constraints = append(m.Constraints, NewConstraint(kind, ps))
+		}
+	})
+}
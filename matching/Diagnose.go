@@ -0,0 +1,200 @@
+package matching
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Conflict explains why no assignment respects the sizes of the groups. Either the persons only wished for the
+// groups, which lack Missing places for them, or the groups need Missing persons more than the persons that wished
+// for them (Underfilled). Current members of the groups already take their places and count for the minimal sizes.
+type Conflict struct {
+	Persons     []*Person
+	Groups      []*Group
+	Missing     int
+	Underfilled bool
+}
+
+// returns the error key of the conflict
+func (c *Conflict) Error() string {
+	if c.Underfilled {
+		return "combination_underfilled"
+	}
+	return "combination_overfilled"
+}
+
+// returns the names of the groups separated by "|" followed by the number of missing places and the names of the persons
+func (c *Conflict) String() string {
+	return c.GroupNames("|") + " (" + strconv.Itoa(c.Missing) + "): " + c.PersonNames(", ")
+}
+
+// returns the names of the persons of the conflict joined by sep
+func (c *Conflict) PersonNames(sep string) string {
+	names := make([]string, len(c.Persons))
+	for i, p := range c.Persons {
+		names[i] = p.Name
+	}
+	return strings.Join(names, sep)
+}
+
+// returns the names of the groups of the conflict joined by sep
+func (c *Conflict) GroupNames(sep string) string {
+	names := make([]string, len(c.Groups))
+	for i, g := range c.Groups {
+		names[i] = g.Name
+	}
+	return strings.Join(names, sep)
+}
+
+// the state of the feasibility check: a current assignment of the groupless persons that is changed along augmenting
+// paths like in a bipartite matching
+type diagnosis struct {
+	persons []*Person
+	wishes  map[*Person][]*Group
+	groupOf map[*Person]*Group
+	load    map[*Group]int
+	visited map[interface{}]bool
+	// the persons assigned to every group and the persons that wished for it
+	assigned map[*Group][]*Person
+	wishedBy map[*Group][]*Person
+}
+
+// Checks whether the groupless persons can be assigned to their wishes so that every group gets between MinSize and
+// Capacity members, ignoring all other rules. Returns nil if that's possible, otherwise the conflict that proves it's
+// not. First every person is assigned without exceeding the capacities, then persons are moved into groups that are
+// too small. If a person can't be assigned, the groups that are reachable by moving other persons are full and only
+// wished by the persons in them (Hall's theorem), the same applies to groups that can't get enough persons.
+func (m *Matcher) Diagnose() *Conflict {
+	d := &diagnosis{persons: GetGrouplessPersons(m.Persons, m.Groups), wishes: make(map[*Person][]*Group),
+		groupOf: make(map[*Person]*Group), load: make(map[*Group]int), assigned: make(map[*Group][]*Person),
+		wishedBy: make(map[*Group][]*Person)}
+	for _, g := range m.Groups {
+		if len(g.Members) > g.Capacity {
+			return &Conflict{Persons: g.Members, Groups: []*Group{g}, Missing: len(g.Members) - g.Capacity}
+		}
+		d.load[g] = len(g.Members)
+	}
+	for _, p := range d.persons {
+		for _, pref := range p.Preferences {
+			if pref.IndexIn(m.Groups) != -1 {
+				d.wishes[p] = append(d.wishes[p], pref)
+				d.wishedBy[pref] = append(d.wishedBy[pref], p)
+			}
+		}
+	}
+
+	// assign every person without exceeding the capacities
+	var unassigned []*Person
+	for _, p := range d.persons {
+		d.visited = make(map[interface{}]bool)
+		if !d.insert(p) {
+			unassigned = append(unassigned, p)
+		}
+	}
+	if len(unassigned) > 0 {
+		// the persons and groups reachable from the first person form the conflict, other unassigned persons that only
+		// wished for these groups increase the missing places
+		d.visited = make(map[interface{}]bool)
+		d.insert(unassigned[0])
+		c := &Conflict{}
+		for _, g := range m.Groups {
+			if d.visited[g] {
+				c.Groups = append(c.Groups, g)
+			}
+		}
+		for _, p := range d.persons {
+			if d.visited[p] || d.groupOf[p] == nil && d.onlyWishes(p) {
+				c.Persons = append(c.Persons, p)
+				if d.groupOf[p] == nil {
+					c.Missing++
+				}
+			}
+		}
+		return c
+	}
+
+	// fill the groups up to their minimal sizes
+	for _, g := range m.Groups {
+		for d.load[g] < g.MinSize {
+			d.visited = make(map[interface{}]bool)
+			if d.fill(g) {
+				continue
+			}
+			// the reachable groups are all at their minimum and contain all persons that wished for them
+			c := &Conflict{Underfilled: true}
+			for _, h := range m.Groups {
+				if d.visited[h] {
+					c.Groups = append(c.Groups, h)
+					c.Missing += h.MinSize - d.load[h]
+				}
+			}
+			for _, p := range d.persons {
+				if d.visited[d.groupOf[p]] {
+					c.Persons = append(c.Persons, p)
+				}
+			}
+			return c
+		}
+	}
+	return nil
+}
+
+// tries to assign the person to one of its wishes, moving other persons to further wishes if necessary
+func (d *diagnosis) insert(p *Person) bool {
+	d.visited[p] = true
+	for _, g := range d.wishes[p] {
+		if d.visited[g] {
+			continue
+		}
+		d.visited[g] = true
+		if d.load[g] < g.Capacity {
+			d.move(p, g)
+			return true
+		}
+		for _, q := range d.assigned[g] {
+			if !d.visited[q] && d.insert(q) {
+				d.move(p, g)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tries to move a person into the group from a group that has more members than needed, possibly through other groups
+func (d *diagnosis) fill(g *Group) bool {
+	d.visited[g] = true
+	for _, q := range d.wishedBy[g] {
+		h := d.groupOf[q]
+		if h == g || d.visited[h] {
+			continue
+		}
+		if d.load[h] > h.MinSize || d.fill(h) {
+			d.move(q, g)
+			return true
+		}
+	}
+	return false
+}
+
+// moves the person into the group
+func (d *diagnosis) move(p *Person, g *Group) {
+	if h := d.groupOf[p]; h != nil {
+		d.load[h]--
+		i := p.IndexIn(d.assigned[h])
+		d.assigned[h] = append(d.assigned[h][:i], d.assigned[h][i+1:]...)
+	}
+	d.groupOf[p] = g
+	d.load[g]++
+	d.assigned[g] = append(d.assigned[g], p)
+}
+
+// whether all wishes of the person are visited groups
+func (d *diagnosis) onlyWishes(p *Person) bool {
+	for _, g := range d.wishes[p] {
+		if !d.visited[g] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package matching
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		name string
+		// capacity and minimal size of the groups A, B, C
+		sizes [][2]int
+		// locked members of the groups as indices of the persons
+		members [][]int
+		// wishes of the persons as indices of the groups
+		wishes [][]int
+		// the conflict as String() returns it, empty if there is none
+		want        string
+		underfilled bool
+		// the error of CheckMatcher, which reports groups with too few candidates before the conflict
+		check string
+	}{
+		{
+			name:   "feasible",
+			sizes:  [][2]int{{2, 1}, {2, 1}, {2, 0}},
+			wishes: [][]int{{0, 1}, {0, 1}, {0}, {2}},
+		},
+		{
+			name:    "feasible with members",
+			sizes:   [][2]int{{2, 2}, {2, 0}, {2, 0}},
+			members: [][]int{{0}, nil, nil},
+			wishes:  [][]int{{0}, {0, 1}, {1}},
+		},
+		{
+			name:   "too many wishes for one group",
+			sizes:  [][2]int{{1, 0}, {2, 0}, {2, 0}},
+			wishes: [][]int{{0}, {0}, {1}},
+			want:   "A (1): a, b",
+			check:  "combination_overfilled",
+		},
+		{
+			name:   "too many wishes for groups reached by moving persons",
+			sizes:  [][2]int{{1, 0}, {1, 0}, {2, 0}},
+			wishes: [][]int{{0}, {0, 1}, {1}, {2}},
+			want:   "A|B (1): a, b, c",
+			check:  "combination_overfilled",
+		},
+		{
+			name:    "places taken by members",
+			sizes:   [][2]int{{2, 0}, {2, 0}, {2, 0}},
+			members: [][]int{{0}, nil, nil},
+			wishes:  [][]int{{0}, {0}, {0}},
+			want:    "A (1): b, c",
+			check:   "combination_overfilled",
+		},
+		{
+			name:    "members above capacity",
+			sizes:   [][2]int{{1, 0}, {2, 0}, {2, 0}},
+			members: [][]int{{0, 1}, nil, nil},
+			wishes:  [][]int{{0}, {0}, {1}},
+			want:    "A (1): a, b",
+			check:   "combination_overfilled",
+		},
+		{
+			name:        "too few wishes for one group",
+			sizes:       [][2]int{{5, 3}, {5, 0}, {5, 0}},
+			wishes:      [][]int{{0, 1}, {1}, {0}},
+			want:        "A (1): a, c",
+			underfilled: true,
+			check:       "person_no_pref",
+		},
+		{
+			name:        "too few persons for all minimal sizes",
+			sizes:       [][2]int{{5, 2}, {5, 2}, {5, 0}},
+			wishes:      [][]int{{0, 1}, {1, 0}, {0, 1}, {2}},
+			want:        "A|B (1): a, b, c",
+			underfilled: true,
+			check:       "combination_underfilled",
+		},
+	}
+
+	for _, test := range tests {
+		groups, persons := newTestProject(test.sizes, test.wishes)
+		for i, members := range test.members {
+			for _, k := range members {
+				groups[i].Members = append(groups[i].Members, persons[k])
+				groups[i].SetLocked(persons[k], true)
+			}
+		}
+
+		c := NewMatcher(persons, groups).Diagnose()
+		if test.want == "" {
+			if c != nil {
+				t.Errorf("%s: unexpected conflict %s", test.name, c)
+			}
+			continue
+		}
+		if c == nil {
+			t.Errorf("%s: no conflict, want %s", test.name, test.want)
+			continue
+		}
+		if c.String() != test.want || c.Underfilled != test.underfilled {
+			t.Errorf("%s: conflict %s (underfilled %v), want %s (underfilled %v)", test.name, c, c.Underfilled, test.want, test.underfilled)
+		}
+		if err, _ := NewMatcher(GetGrouplessPersons(persons, groups), groups).CheckMatcher(); err == nil || err.Error() != test.check {
+			t.Errorf("%s: CheckMatcher returns %v, want %s", test.name, err, test.check)
+		}
+	}
+}
+
+// the diagnosis finds a conflict exactly for the projects without an assignment that respects the group sizes, which
+// is proven by the persons and groups of the conflict
+func TestDiagnoseRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for it := 0; it < 1000; it++ {
+		n := 2 + rnd.Intn(5)
+		var groups []*Group
+		for i := 0; i < n; i++ {
+			min := rnd.Intn(4)
+			groups = append(groups, NewGroup(string(rune('A'+i)), min+rnd.Intn(4), min))
+		}
+		var persons []*Person
+		for i := rnd.Intn(15); i > 0; i-- {
+			var preferences []*Group
+			for _, k := range rnd.Perm(n)[:1+rnd.Intn(n)] {
+				preferences = append(preferences, groups[k])
+			}
+			persons = append(persons, NewPerson(string(rune('a'+i)), preferences))
+		}
+
+		m := NewMatcher(persons, groups)
+		_, feasible := m.flowAssignment(persons, func(*Person, *Group) bool { return true })
+		c := m.Diagnose()
+		if feasible != (c == nil) {
+			t.Fatalf("project %d: feasible is %v, but the conflict is %v", it, feasible, c)
+		}
+		if c == nil {
+			continue
+		}
+
+		inConflict := func(g *Group) bool {
+			return g.IndexIn(c.Groups) != -1
+		}
+		if !c.Underfilled {
+			// the persons only wished for the groups, which are too small for them
+			places := 0
+			for _, g := range c.Groups {
+				places += g.Capacity
+			}
+			for _, p := range c.Persons {
+				for _, g := range p.Preferences {
+					if !inConflict(g) {
+						t.Fatalf("project %d: %s of the conflict %s wished for %s", it, p.Name, c, g.Name)
+					}
+				}
+			}
+			if c.Missing <= 0 || len(c.Persons)-places != c.Missing {
+				t.Fatalf("project %d: conflict %s with %d places", it, c, places)
+			}
+			continue
+		}
+		// all persons that wished for the groups are too few for their minimal sizes
+		needed, wishing := 0, 0
+		for _, g := range c.Groups {
+			needed += g.MinSize
+		}
+		for _, p := range persons {
+			for _, g := range p.Preferences {
+				if inConflict(g) {
+					wishing++
+					if p.IndexIn(c.Persons) == -1 {
+						t.Fatalf("project %d: %s wished for a group of the conflict %s", it, p.Name, c)
+					}
+					break
+				}
+			}
+		}
+		if c.Missing <= 0 || wishing != len(c.Persons) || needed-wishing != c.Missing {
+			t.Fatalf("project %d: conflict %s needs %d persons", it, c, needed)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package matching
+
+// a wish of a person to be in the same group as a friend
+type friendship struct {
+	p, friend *Person
+}
+
+// returns the friend wishes between the persons of the matcher
+func (m *Matcher) friendships() []friendship {
+	persons := m.allPersons()
+	var friendships []friendship
+	for _, p := range persons {
+		for _, f := range p.Friends {
+			if f.IndexIn(persons) != -1 {
+				friendships = append(friendships, friendship{p, f})
+			}
+		}
+	}
+	return friendships
+}
+
+// whether both persons are assigned, but to different groups
+func (f friendship) split(groupOf func(*Person) *Group) bool {
+	g, h := groupOf(f.p), groupOf(f.friend)
+	return g != nil && h != nil && g != h
+}
+
+// counts the friend wishes that are not fulfilled, wishes of unassigned persons or for unassigned friends are ignored
+func splitFriendships(friendships []friendship, groupOf func(*Person) *Group) (n int) {
+	for _, f := range friendships {
+		if f.split(groupOf) {
+			n++
+		}
+	}
+	return
+}
+
+// returns a person of a split friendship that isn't fixed yet together with its group, nil if every split is unavoidable
+func branchFriendships(friendships []friendship, groupOf func(*Person) *Group, fixed func(*Person) bool) (*Person, *Group) {
+	for _, f := range friendships {
+		if !f.split(groupOf) {
+			continue
+		}
+		if !fixed(f.p) {
+			return f.p, groupOf(f.p)
+		}
+		if !fixed(f.friend) {
+			return f.friend, groupOf(f.friend)
+		}
+	}
+	return nil, nil
+}
+
+// returns the group of every person in the assignment or in the groups of the matcher
+func (m *Matcher) groupOf(a Assignment) func(*Person) *Group {
+	members := make(map[*Person]*Group)
+	for _, g := range m.Groups {
+		for _, p := range g.Members {
+			members[p] = g
+		}
+	}
+	return func(p *Person) *Group {
+		if g := members[p]; g != nil {
+			return g
+		}
+		return a[p]
+	}
+}
+
+// the rank costs of the assignment plus the costs of the friend wishes it doesn't fulfill
+func (m *Matcher) cost(a Assignment, rc RankCosts) int {
+	n := a.cost(rc)
+	if friendships := m.friendships(); len(friendships) > 0 {
+		n += rc.FriendCost() * splitFriendships(friendships, m.groupOf(a))
+	}
+	return n
+}
+
+// counts the friend wishes of the persons and how many of them are split by the current groups, with slots the wishes
+// are counted for every slot
+func SplitFriendships(persons []*Person, groups []*Group) (split, total int) {
+	friendships := NewMatcher(persons, groups).friendships()
+	for _, groupOf := range slotGroupOfs(groups) {
+		split += splitFriendships(friendships, groupOf)
+		total += len(friendships)
+	}
+	return
+}
+
+// returns how many friends of the person are in the given group
+func FriendsInGroup(p *Person, g *Group) int {
+	n := 0
+	for _, f := range p.Friends {
+		if f.IndexIn(g.Members) != -1 {
+			n++
+		}
+	}
+	return n
+}
@@ -7,6 +7,10 @@ type Group struct {
 	MinSize  int
 	Capacity int
 	Name     string
+	// the slot the group belongs to, empty if the project has no slots
+	Slot string
+	// members that were assigned by hand and must not be moved by the solvers
+	Locked []*Person
 }
 
 func NewGroup(name string, capacity, minSize int) *Group {
@@ -27,9 +31,40 @@ func (value *Group) IndexIn(slice []*Group) int {
 }
 
 func (g *Group) StringWithSize() string {
+	if g.Slot != "" {
+		return fmt.Sprintf("%s: %s (%d/%d-%d)", g.Slot, g.Name, len(g.Members), g.MinSize, g.Capacity)
+	}
 	return fmt.Sprintf("%s (%d/%d-%d)", g.Name, len(g.Members), g.MinSize, g.Capacity)
 }
 
+// whether the person is a locked member of the group
+func (g *Group) IsLocked(p *Person) bool {
+	return p.IndexIn(g.Locked) != -1
+}
+
+// locks or unlocks a member of the group
+func (g *Group) SetLocked(p *Person, locked bool) {
+	i := p.IndexIn(g.Locked)
+	if locked && i == -1 && p.IndexIn(g.Members) != -1 {
+		g.Locked = append(g.Locked, p)
+	} else if !locked && i != -1 {
+		g.Locked = append(g.Locked[:i], g.Locked[i+1:]...)
+	}
+}
+
+// removes all members from the groups except the locked ones
+func ResetGroups(groups []*Group) {
+	for _, g := range groups {
+		members := make([]*Person, 0, len(g.Locked))
+		for _, p := range g.Members {
+			if g.IsLocked(p) {
+				members = append(members, p)
+			}
+		}
+		g.Members = members
+	}
+}
+
 func (g *Group) deletePerson(p *Person) {
 	for i := 0; i < len(g.Members); i++ {
 		if g.Members[i] == p {
@@ -41,10 +76,10 @@ func (g *Group) deletePerson(p *Person) {
 //adds a fitting person to the given group
 func (g *Group) insertBestFrom(candidates []*Person, m *Matcher) {
 	//searching with decreasing preference priority
-	for i := 0; i < 3; i++ {
+	for i := 0; i < MaxPreferences(candidates); i++ {
 		//j := range candidates isn't possible because of changing slice length
 		for j := len(candidates) - 1; j >= 0; j-- {
-			if candidates[j].Preferences[i] == g {
+			if i < len(candidates[j].Preferences) && candidates[j].Preferences[i] == g {
 				g.Members = append(g.Members, candidates[j])
 				m.getHostGroup(candidates[j]).deletePerson(candidates[j])
 				return
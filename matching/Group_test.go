@@ -0,0 +1,167 @@
+package matching
+
+import (
+	"context"
+	"testing"
+)
+
+// returns a project in which a and b are locked in the groups they didn't wish for first
+func lockedTestProject() ([]*Group, []*Person) {
+	groups, persons := newTestProject([][2]int{{2, 0}, {2, 0}, {2, 0}},
+		[][]int{{0, 1}, {1, 0}, {0, 1}, {0, 1}, {1, 2}, {2, 0}})
+	a, b := persons[0], persons[1]
+	groups[1].Members = []*Person{a}
+	groups[0].Members = []*Person{b}
+	groups[1].SetLocked(a, true)
+	groups[0].SetLocked(b, true)
+	return groups, persons
+}
+
+// checks that a and b of lockedTestProject are still locked in their groups
+func checkLocks(t *testing.T, name string, groups []*Group, persons []*Person) {
+	a, b := persons[0], persons[1]
+	if a.GetGroup(groups) != groups[1] || !groups[1].IsLocked(a) || b.GetGroup(groups) != groups[0] || !groups[0].IsLocked(b) {
+		t.Errorf("%s: the locked persons were moved: %v", name, groups)
+	}
+}
+
+func TestLockedMembersStay(t *testing.T) {
+	for _, solver := range SolverNames() {
+		groups, persons := lockedTestProject()
+		m := NewMatcher(GetGrouplessPersons(persons, groups), groups)
+		if err, names := m.CheckMatcher(); err != nil {
+			t.Fatalf("%s: %v: %s", solver, err, names)
+		}
+		if _, err := m.Solve(context.Background(), testOptions(solver)); err != nil {
+			t.Errorf("%s: %v", solver, err)
+			continue
+		}
+		checkLocks(t, solver, groups, persons)
+		if groupless := GetGrouplessPersons(persons, groups); len(groupless) > 0 {
+			t.Errorf("%s: %v are groupless", solver, groupless)
+		}
+		for _, g := range groups {
+			if len(g.Members) > g.Capacity {
+				t.Errorf("%s: group %s has %d members", solver, g.Name, len(g.Members))
+			}
+		}
+	}
+}
+
+func TestResetGroups(t *testing.T) {
+	groups, persons := lockedTestProject()
+	c, d := persons[2], persons[3]
+	groups[0].Members = append(groups[0].Members, c)
+	groups[2].Members = append(groups[2].Members, d)
+	ResetGroups(groups)
+	checkLocks(t, "reset", groups, persons)
+	if c.GetGroup(groups) != nil || d.GetGroup(groups) != nil {
+		t.Errorf("unlocked members are kept: %v", groups)
+	}
+}
+
+func TestSetLocked(t *testing.T) {
+	groups, persons := newTestProject([][2]int{{2, 0}}, [][]int{{0}, {0}})
+	a, b := persons[0], persons[1]
+	g := groups[0]
+	g.Members = []*Person{a}
+	g.SetLocked(b, true)
+	if g.IsLocked(b) {
+		t.Error("a person that isn't a member is locked")
+	}
+	g.SetLocked(a, true)
+	g.SetLocked(a, true)
+	if !g.IsLocked(a) || len(g.Locked) != 1 {
+		t.Errorf("locked members are %v", g.Locked)
+	}
+	g.SetLocked(a, false)
+	if g.IsLocked(a) || len(g.Locked) != 0 {
+		t.Errorf("locked members are %v after unlocking", g.Locked)
+	}
+}
+
+func TestCheckLocks(t *testing.T) {
+	tests := []struct {
+		name string
+		// members of the groups A, B, C as indices of the persons, all of them are locked except for unlocked
+		members  [][]int
+		unlocked int
+		want     string
+	}{
+		{
+			name:     "locks leave enough places",
+			members:  [][]int{{0}, {1}, nil},
+			unlocked: -1,
+		},
+		{
+			name:     "unlocked member",
+			members:  [][]int{{0}, {1}, nil},
+			unlocked: 1,
+			want:     "assigned_persons",
+		},
+		{
+			name:     "locks leave too few places for the others",
+			members:  [][]int{{5, 6}, {3}, nil},
+			unlocked: -1,
+			want:     "combination_overfilled",
+		},
+		{
+			name:     "locks exceed the capacity",
+			members:  [][]int{nil, nil, {4, 5, 6}},
+			unlocked: -1,
+			want:     "combination_overfilled",
+		},
+	}
+
+	for _, test := range tests {
+		groups, persons := newTestProject([][2]int{{3, 0}, {3, 0}, {2, 0}},
+			[][]int{{0, 1}, {0, 1}, {0, 1}, {1, 0}, {0, 1}, {2}, {2}})
+		for i, members := range test.members {
+			for _, k := range members {
+				groups[i].Members = append(groups[i].Members, persons[k])
+				if k != test.unlocked {
+					groups[i].SetLocked(persons[k], true)
+				}
+			}
+		}
+		err, names := NewMatcher(GetGrouplessPersons(persons, groups), groups).CheckMatcher()
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v: %s", test.name, err, names)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%s: CheckMatcher returns %v: %s, want %s", test.name, err, names, test.want)
+		}
+	}
+}
+
+// in incremental mode members that aren't locked keep their groups as well
+func TestIncremental(t *testing.T) {
+	for _, solver := range SolverNames() {
+		groups, persons := lockedTestProject()
+		c := persons[2]
+		groups[2].Members = append(groups[2].Members, c)
+		m := NewMatcher(GetGrouplessPersons(persons, groups), groups)
+		if err, _ := m.CheckMatcher(); err == nil || err.Error() != "assigned_persons" {
+			t.Errorf("%s: CheckMatcher returns %v without incremental mode", solver, err)
+		}
+		m = NewMatcher(GetGrouplessPersons(persons, groups), groups)
+		m.Incremental = true
+		if err, names := m.CheckMatcher(); err != nil {
+			t.Fatalf("%s: %v: %s", solver, err, names)
+		}
+		if _, err := m.Solve(context.Background(), testOptions(solver)); err != nil {
+			t.Errorf("%s: %v", solver, err)
+			continue
+		}
+		checkLocks(t, solver, groups, persons)
+		if c.GetGroup(groups) != groups[2] || groups[2].IsLocked(c) {
+			t.Errorf("%s: the assigned person was moved or locked: %v", solver, groups)
+		}
+		if groupless := GetGrouplessPersons(persons, groups); len(groupless) > 0 {
+			t.Errorf("%s: %v are groupless", solver, groupless)
+		}
+	}
+}
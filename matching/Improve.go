@@ -0,0 +1,54 @@
+package matching
+
+import "context"
+
+// Improves the current assignment, which may also be made by hand, by a local search of single moves, swaps, moves
+// into groups with spare places and ejection chains. Only unlocked members of groups they wished for are moved, and
+// only if no group size or rule that is respected now gets violated. Groupless persons stay groupless.
+// Returns the moved persons ordered by the groups they were moved from, the other members keep their order.
+// The assignment is improved with respect to opts.Objective.
+func (m *Matcher) Improve(ctx context.Context, opts Options) []Move {
+	persons := m.allPersons()
+	c, before, restore := m.rematcher(nil)
+	c.Persons = nil
+	for _, p := range persons {
+		if before[p] != nil {
+			c.Persons = append(c.Persons, p)
+		}
+	}
+
+	if Slots(c.Groups) == nil {
+		a := make(Assignment)
+		for _, p := range c.Persons {
+			a[p] = before[p][0]
+		}
+		rc, _ := opts.objectiveCosts(c.Persons)
+		c.Apply(c.improved(ctx, a, rc))
+	} else {
+		// only the copies for the slots in which the persons had a group are moved
+		s := c.slotted()
+		a := make(Assignment)
+		var copies []*Person
+		for _, cp := range s.Persons {
+			for _, g := range before[s.person[cp]] {
+				if g.Slot == s.slot[cp] {
+					a[cp] = FindGroupInSlot(g.Name, g.Slot, s.Groups)
+					copies = append(copies, cp)
+				}
+			}
+		}
+		s.Persons = copies
+		rc, _ := opts.objectiveCosts(copies)
+		s.apply(s.improved(ctx, a, rc))
+	}
+
+	moves := m.moves(c.Persons, before, nil)
+	restore()
+	for _, mv := range moves {
+		mv.From.deletePerson(mv.Person)
+		if mv.To != nil {
+			mv.To.Members = append(mv.To.Members, mv.Person)
+		}
+	}
+	return moves
+}
@@ -0,0 +1,97 @@
+package matching
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+// returns the sum of the rank costs of all members of the groups
+func assignedCosts(groups []*Group, rc RankCosts) int {
+	costs := 0
+	for _, g := range groups {
+		for _, p := range g.Members {
+			costs += rc.Cost(p, g)
+		}
+	}
+	return costs
+}
+
+func TestImproveSwap(t *testing.T) {
+	groups, persons := newTestProject([][2]int{{1, 0}, {1, 0}, {1, 0}}, [][]int{{0, 1}, {1, 0}, {0, 2}})
+	a, b, c := persons[0], persons[1], persons[2]
+	groups[1].Members = []*Person{a}
+	groups[0].Members = []*Person{b}
+	groups[2].Members = []*Person{c}
+	groups[2].SetLocked(c, true)
+	moves := NewMatcher(nil, groups).Improve(context.Background(), DefaultOptions())
+	if a.GetGroup(groups) != groups[0] || b.GetGroup(groups) != groups[1] || len(moves) != 2 {
+		t.Errorf("a and b aren't swapped: %v with moves %s", groups, describeMoves(moves))
+	}
+	if c.GetGroup(groups) != groups[2] || !groups[2].IsLocked(c) {
+		t.Errorf("the locked person was moved: %v", groups)
+	}
+}
+
+// the improvement never makes an assignment worse for the objective, keeps the locked members and respects the
+// group sizes
+func TestImproveRandom(t *testing.T) {
+	for _, objective := range []string{"sum", "rank_maximal", "leximin"} {
+		opts := DefaultOptions()
+		opts.Objective = objective
+		improveRandom(t, opts)
+	}
+}
+
+func improveRandom(t *testing.T, opts Options) {
+	rnd := rand.New(rand.NewSource(1))
+	for it := 0; it < 200; it++ {
+		n := 2 + rnd.Intn(4)
+		sizes := make([][2]int, n)
+		for i := range sizes {
+			sizes[i] = [2]int{1 + rnd.Intn(4), 0}
+		}
+		wishes := make([][]int, 2+rnd.Intn(10))
+		for i := range wishes {
+			wishes[i] = rnd.Perm(n)[:1+rnd.Intn(n)]
+		}
+		groups, persons := newTestProject(sizes, wishes)
+
+		// assign the persons to their last wish with a free place and lock some of them
+		locked := make(map[*Person]*Group)
+		for _, p := range persons {
+			for k := len(p.Preferences) - 1; k >= 0; k-- {
+				g := p.Preferences[k]
+				if len(g.Members) < g.Capacity {
+					g.Members = append(g.Members, p)
+					if rnd.Intn(4) == 0 {
+						g.SetLocked(p, true)
+						locked[p] = g
+					}
+					break
+				}
+			}
+		}
+		groupless := len(GetGrouplessPersons(persons, groups))
+		rc, _ := opts.objectiveCosts(persons)
+		costs := assignedCosts(groups, rc)
+
+		NewMatcher(nil, groups).Improve(context.Background(), opts)
+		if got := assignedCosts(groups, rc); got > costs {
+			t.Fatalf("%s, iteration %d: the costs rose from %d to %d", opts.Objective, it, costs, got)
+		}
+		for p, g := range locked {
+			if p.GetGroup(groups) != g || !g.IsLocked(p) {
+				t.Fatalf("%s, iteration %d: the locked person %s was moved", opts.Objective, it, p.Name)
+			}
+		}
+		for _, g := range groups {
+			if len(g.Members) > g.Capacity {
+				t.Fatalf("%s, iteration %d: group %s has %d members", opts.Objective, it, g.Name, len(g.Members))
+			}
+		}
+		if got := len(GetGrouplessPersons(persons, groups)); got != groupless {
+			t.Fatalf("%s, iteration %d: %d persons are groupless, before %d", opts.Objective, it, got, groupless)
+		}
+	}
+}
@@ -5,73 +5,314 @@ import (
 	"errors"
 )
 
-type jsonGroup struct {
+// JSON representation of a group, members and locked members are given by their index in the persons
+type JSONGroup struct {
 	Name     string `json:"name"`
 	MinSize  int    `json:"min_size"`
 	Capacity int    `json:"capacity"`
 	Members  []int  `json:"members"`
+	Slot     string `json:"slot,omitempty"`
+	Locked   []int  `json:"locked,omitempty"`
 }
 
-type jsonPerson struct {
-	Name        string `json:"name"`
-	Preferences []int  `json:"preferences"`
+// JSON representation of a person, preferences are given by their index in the groups, friends by their index
+type JSONPerson struct {
+	Name        string            `json:"name"`
+	Preferences []int             `json:"preferences"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Friends     []int             `json:"friends,omitempty"`
 }
 
-type jsonStore struct {
-	Groups  []jsonGroup  `json:"groups"`
-	Persons []jsonPerson `json:"persons"`
+// JSON representation of a constraint, persons are given by their index
+type JSONConstraint struct {
+	Kind    string `json:"kind"`
+	Persons []int  `json:"persons"`
+}
+
+// JSON representation of a balance rule, the group is given by its index and omitted for rules of all groups
+type JSONBalanceRule struct {
+	Group   *int   `json:"group,omitempty"`
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`
+	AtLeast bool   `json:"at_least"`
+	Amount  int    `json:"amount"`
+	Percent bool   `json:"percent"`
+}
+
+// JSON representation of groups and persons
+type JSONStore struct {
+	Groups      []JSONGroup       `json:"groups"`
+	Persons     []JSONPerson      `json:"persons"`
+	Constraints []JSONConstraint  `json:"constraints,omitempty"`
+	Balance     []JSONBalanceRule `json:"balance,omitempty"`
 }
 
 func ToJSON(groups []*Group, persons []*Person) ([]byte, error) {
-	jsonGroups := make([]jsonGroup, len(groups))
-	jsonPersons := make([]jsonPerson, len(persons))
+	return json.Marshal(NewJSONStore(groups, persons))
+}
+
+// converts groups and persons to their JSON representation
+func NewJSONStore(groups []*Group, persons []*Person) JSONStore {
+	jsonGroups := make([]JSONGroup, len(groups))
+	jsonPersons := make([]JSONPerson, len(persons))
 	for i := range persons {
-		jsonPersons[i] = jsonPerson{Name: persons[i].Name, Preferences: make([]int, len(persons[i].Preferences))}
+		jsonPersons[i] = JSONPerson{Name: persons[i].Name, Preferences: make([]int, len(persons[i].Preferences)), Attributes: copyAttributes(persons[i].Attributes)}
 	}
 	for i, group := range groups {
-		jsonGroups[i] = jsonGroup{Name: group.Name, MinSize: group.MinSize, Capacity: group.Capacity, Members: make([]int, len(group.Members))}
+		jsonGroups[i] = JSONGroup{Name: group.Name, MinSize: group.MinSize, Capacity: group.Capacity, Members: make([]int, len(group.Members)), Slot: group.Slot}
 		for j, member := range group.Members {
 			jsonGroups[i].Members[j] = member.IndexIn(persons)
 		}
+		for _, member := range group.Locked {
+			jsonGroups[i].Locked = append(jsonGroups[i].Locked, member.IndexIn(persons))
+		}
 	}
 	for i := range persons {
 		for j, pref := range persons[i].Preferences {
 			jsonPersons[i].Preferences[j] = pref.IndexIn(groups)
 		}
+		for _, f := range persons[i].Friends {
+			if k := f.IndexIn(persons); k != -1 {
+				jsonPersons[i].Friends = append(jsonPersons[i].Friends, k)
+			}
+		}
 	}
-	jsonS := jsonStore{Groups: jsonGroups, Persons: jsonPersons}
-	return json.Marshal(jsonS)
+	return JSONStore{Groups: jsonGroups, Persons: jsonPersons}
+}
+
+// converts constraints to their JSON representation
+func NewJSONConstraints(constraints []*Constraint, persons []*Person) []JSONConstraint {
+	jsonConstraints := make([]JSONConstraint, len(constraints))
+	for i, c := range constraints {
+		jsonConstraints[i] = JSONConstraint{Kind: c.Kind, Persons: make([]int, len(c.Persons))}
+		for j, p := range c.Persons {
+			jsonConstraints[i].Persons[j] = p.IndexIn(persons)
+		}
+	}
+	return jsonConstraints
+}
+
+// converts the JSON representation of the constraints back, persons must be decoded from the same store
+func (store JSONStore) DecodeConstraints(persons []*Person) ([]*Constraint, error) {
+	constraints := make([]*Constraint, len(store.Constraints))
+	for i, c := range store.Constraints {
+		if c.Kind != Together && c.Kind != Apart {
+			return nil, errors.New("invalid_constraint")
+		}
+		constraints[i] = NewConstraint(c.Kind, make([]*Person, len(c.Persons)))
+		for j, k := range c.Persons {
+			if k < 0 || k >= len(persons) {
+				return nil, errors.New("index_out_of_range")
+			}
+			constraints[i].Persons[j] = persons[k]
+		}
+	}
+	return constraints, nil
+}
+
+// converts balance rules to their JSON representation
+func NewJSONBalance(rules []*BalanceRule, groups []*Group) []JSONBalanceRule {
+	jsonRules := make([]JSONBalanceRule, len(rules))
+	for i, r := range rules {
+		jsonRules[i] = JSONBalanceRule{Key: r.Key, Value: r.Value, AtLeast: r.AtLeast, Amount: r.Amount, Percent: r.Percent}
+		if r.Group != nil {
+			index := r.Group.IndexIn(groups)
+			jsonRules[i].Group = &index
+		}
+	}
+	return jsonRules
+}
+
+// converts the JSON representation of the balance rules back, groups must be decoded from the same store
+func (store JSONStore) DecodeBalance(groups []*Group) ([]*BalanceRule, error) {
+	rules := make([]*BalanceRule, len(store.Balance))
+	for i, r := range store.Balance {
+		if r.Key == "" || r.Amount < 0 || (r.Percent && r.Amount > 100) {
+			return nil, errors.New("invalid_balance_rule")
+		}
+		rules[i] = &BalanceRule{Key: r.Key, Value: r.Value, AtLeast: r.AtLeast, Amount: r.Amount, Percent: r.Percent}
+		if r.Group != nil {
+			if *r.Group < 0 || *r.Group >= len(groups) {
+				return nil, errors.New("index_out_of_range")
+			}
+			rules[i].Group = groups[*r.Group]
+		}
+	}
+	return rules, nil
+}
+
+// returns a copy of the attributes, nil if there are none
+func copyAttributes(attributes map[string]string) map[string]string {
+	if len(attributes) == 0 {
+		return nil
+	}
+	c := make(map[string]string, len(attributes))
+	for k, v := range attributes {
+		c[k] = v
+	}
+	return c
 }
 
 func FromJSON(encoded []byte) (groups []*Group, persons []*Person, err error) {
-	store := jsonStore{}
+	store := JSONStore{}
 	err = json.Unmarshal(encoded, &store)
 	if err != nil {
 		return
 	}
+	return store.Decode()
+}
+
+// returns an error if two persons or two groups of the same slot have the same name, which is allowed for the copies
+// of the persons that are matched (see matchMany) but not in projects
+func (store JSONStore) CheckNames() error {
+	persons := make(map[string]bool)
+	for _, p := range store.Persons {
+		if persons[p.Name] {
+			return errors.New("person_name_not_unique")
+		}
+		persons[p.Name] = true
+	}
+	groups := make(map[[2]string]bool)
+	for _, g := range store.Groups {
+		if groups[[2]string{g.Slot, g.Name}] {
+			return errors.New("group_name_not_unique")
+		}
+		groups[[2]string{g.Slot, g.Name}] = true
+	}
+	return nil
+}
+
+// converts the JSON representation back to groups and persons, errors are given by their key
+func (store JSONStore) Decode() (groups []*Group, persons []*Person, err error) {
 	jsonGroups := store.Groups
 	jsonPersons := store.Persons
 	groups = make([]*Group, len(jsonGroups))
 	persons = make([]*Person, len(jsonPersons))
 	for i := range jsonPersons {
-		persons[i] = &Person{Name: jsonPersons[i].Name, Preferences: make([]*Group, len(jsonPersons[i].Preferences))}
+		persons[i] = &Person{Name: jsonPersons[i].Name, Preferences: make([]*Group, len(jsonPersons[i].Preferences)), Attributes: copyAttributes(jsonPersons[i].Attributes)}
 	}
 	for i := range jsonGroups {
-		groups[i] = &Group{Name: jsonGroups[i].Name, MinSize: jsonGroups[i].MinSize, Capacity: jsonGroups[i].Capacity, Members: make([]*Person, len(jsonGroups[i].Members))}
+		if jsonGroups[i].MinSize < 0 || jsonGroups[i].MinSize > jsonGroups[i].Capacity {
+			return nil, nil, errors.New("invalid_group_size")
+		}
+		groups[i] = &Group{Name: jsonGroups[i].Name, MinSize: jsonGroups[i].MinSize, Capacity: jsonGroups[i].Capacity, Members: make([]*Person, len(jsonGroups[i].Members)), Slot: jsonGroups[i].Slot}
 		for j, k := range jsonGroups[i].Members {
 			if k < 0 || k >= len(persons) {
-				return nil, nil, errors.New("Person index out of range!")
+				return nil, nil, errors.New("index_out_of_range")
+			}
+			if persons[k].IndexIn(groups[i].Members[:j]) != -1 {
+				return nil, nil, errors.New("duplicate_member")
+			}
+			// every person has at most one group in every slot, without slots at most one group at all
+			if persons[k].GroupInSlot(groups[:i], groups[i].Slot) != nil {
+				return nil, nil, errors.New("person_twice_in_slot")
 			}
 			groups[i].Members[j] = persons[k]
 		}
+		for _, k := range jsonGroups[i].Locked {
+			if k < 0 || k >= len(persons) {
+				return nil, nil, errors.New("index_out_of_range")
+			}
+			if persons[k].IndexIn(groups[i].Members) == -1 {
+				return nil, nil, errors.New("locked_not_member")
+			}
+			groups[i].Locked = append(groups[i].Locked, persons[k])
+		}
 	}
 	for i := range jsonPersons {
 		for j, k := range jsonPersons[i].Preferences {
 			if k < 0 || k >= len(groups) {
-				return nil, nil, errors.New("Group index out of range!")
+				return nil, nil, errors.New("index_out_of_range")
 			}
 			persons[i].Preferences[j] = groups[k]
 		}
+		for _, k := range jsonPersons[i].Friends {
+			if k < 0 || k >= len(persons) {
+				return nil, nil, errors.New("index_out_of_range")
+			}
+			if k == i {
+				return nil, nil, errors.New("invalid_friend")
+			}
+			persons[i].Friends = append(persons[i].Friends, persons[k])
+		}
 	}
 	return
 }
+
+// name and version of the JSON project format, stored in every .gm.json file
+const (
+	JSONFormat  = "groupmatcher"
+	JSONVersion = 1
+)
+
+// JSON representation of a whole project as stored in .gm.json files, see documentation/gm.schema.json
+// options are given by the names used in project files and only need to contain the ones that differ from the defaults
+type JSONProject struct {
+	Format   string            `json:"format"`
+	Version  int               `json:"version"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Options  map[string]string `json:"options,omitempty"`
+	JSONStore
+}
+
+// converts a project to its JSON representation
+func NewJSONProject(project *Project) JSONProject {
+	store := NewJSONStore(project.Groups, project.Persons)
+	store.Constraints = NewJSONConstraints(project.Constraints, project.Persons)
+	store.Balance = NewJSONBalance(project.Balance, project.Groups)
+	p := JSONProject{Format: JSONFormat, Version: JSONVersion, Metadata: project.Metadata, Options: make(map[string]string), JSONStore: store}
+	for _, pair := range project.Options.Pairs() {
+		p.Options[pair[0]] = pair[1]
+	}
+	return p
+}
+
+// converts the JSON representation back to a project, errors are given by their key
+func (p JSONProject) Decode() (*Project, error) {
+	if p.Format != JSONFormat {
+		return nil, errors.New("syntax_error")
+	}
+	if p.Version != JSONVersion {
+		return nil, errors.New("unsupported_version")
+	}
+	err := p.CheckNames()
+	if err != nil {
+		return nil, err
+	}
+	groups, persons, err := p.JSONStore.Decode()
+	if err != nil {
+		return nil, err
+	}
+	project := NewProject(groups, persons)
+	project.Constraints, err = p.DecodeConstraints(persons)
+	if err != nil {
+		return nil, err
+	}
+	project.Balance, err = p.DecodeBalance(groups)
+	if err != nil {
+		return nil, err
+	}
+	err = project.Options.SetMap(p.Options)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Metadata) > 0 {
+		project.Metadata = p.Metadata
+	}
+	return project, nil
+}
+
+// encodes a project in the format of .gm.json files
+func ProjectToJSON(project *Project) ([]byte, error) {
+	return json.MarshalIndent(NewJSONProject(project), "", "\t")
+}
+
+// decodes a project from the format of .gm.json files
+func ProjectFromJSON(encoded []byte) (*Project, error) {
+	p := JSONProject{}
+	err := json.Unmarshal(encoded, &p)
+	if err != nil {
+		return nil, errors.New("syntax_error")
+	}
+	return p.Decode()
+}
@@ -0,0 +1,117 @@
+package matching
+
+import (
+	"testing"
+)
+
+func TestJSONProjectDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		// changes the valid store of the groups A and B of slot "1" with the members a and b and the persons a, b, c
+		change func(s *JSONStore)
+		err    string
+	}{
+		{
+			name:   "valid",
+			change: func(s *JSONStore) {},
+		},
+		{
+			name: "same group name in another slot",
+			change: func(s *JSONStore) {
+				s.Groups = append(s.Groups, JSONGroup{Name: "A", Capacity: 2, Slot: "2", Members: []int{0}})
+			},
+		},
+		{
+			name:   "minimal size above the capacity",
+			change: func(s *JSONStore) { s.Groups[1].MinSize = 3 },
+			err:    "invalid_group_size",
+		},
+		{
+			name:   "negative minimal size",
+			change: func(s *JSONStore) { s.Groups[1].MinSize = -1 },
+			err:    "invalid_group_size",
+		},
+		{
+			name:   "member out of range",
+			change: func(s *JSONStore) { s.Groups[1].Members = []int{3} },
+			err:    "index_out_of_range",
+		},
+		{
+			name:   "member twice in a group",
+			change: func(s *JSONStore) { s.Groups[1].Members = []int{1, 1} },
+			err:    "duplicate_member",
+		},
+		{
+			name:   "person in two groups of a slot",
+			change: func(s *JSONStore) { s.Groups[1].Members = []int{0} },
+			err:    "person_twice_in_slot",
+		},
+		{
+			name:   "locked person that isn't a member",
+			change: func(s *JSONStore) { s.Groups[0].Locked = []int{2} },
+			err:    "locked_not_member",
+		},
+		{
+			name:   "locked person out of range",
+			change: func(s *JSONStore) { s.Groups[0].Locked = []int{-1} },
+			err:    "index_out_of_range",
+		},
+		{
+			name:   "duplicate group name",
+			change: func(s *JSONStore) { s.Groups[1].Name = "A" },
+			err:    "group_name_not_unique",
+		},
+		{
+			name:   "duplicate person name",
+			change: func(s *JSONStore) { s.Persons[2].Name = "a" },
+			err:    "person_name_not_unique",
+		},
+		{
+			name:   "preference out of range",
+			change: func(s *JSONStore) { s.Persons[2].Preferences = []int{2} },
+			err:    "index_out_of_range",
+		},
+		{
+			name:   "friend of itself",
+			change: func(s *JSONStore) { s.Persons[2].Friends = []int{2} },
+			err:    "invalid_friend",
+		},
+		{
+			name:   "unknown kind of constraint",
+			change: func(s *JSONStore) { s.Constraints = []JSONConstraint{{Kind: "near", Persons: []int{0, 1}}} },
+			err:    "invalid_constraint",
+		},
+		{
+			name:   "balance rule without key",
+			change: func(s *JSONStore) { s.Balance = []JSONBalanceRule{{Amount: 1}} },
+			err:    "invalid_balance_rule",
+		},
+	}
+
+	for _, test := range tests {
+		p := JSONProject{Format: JSONFormat, Version: JSONVersion, JSONStore: JSONStore{
+			Groups: []JSONGroup{
+				{Name: "A", Capacity: 2, Slot: "1", Members: []int{0}, Locked: []int{0}},
+				{Name: "B", Capacity: 2, Slot: "1", Members: []int{1}},
+			},
+			Persons: []JSONPerson{
+				{Name: "a", Preferences: []int{0, 1}},
+				{Name: "b", Preferences: []int{1}},
+				{Name: "c", Preferences: []int{0}, Friends: []int{0}},
+			},
+		}}
+		test.change(&p.JSONStore)
+		project, err := p.Decode()
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			} else if !project.Groups[0].IsLocked(project.Persons[0]) {
+				t.Errorf("%s: the lock is lost", test.name)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: error %v, want %s", test.name, err, test.err)
+		}
+	}
+}
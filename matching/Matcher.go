@@ -2,29 +2,59 @@
 package matching
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"sync"
 	"time"
 )
 
 type Matcher struct {
-	Persons []*Person
-	Groups  []*Group
+	Persons     []*Person
+	Groups      []*Group
+	RankCosts   RankCosts
+	Constraints []*Constraint
+	Balance     []*BalanceRule
+	// members of the groups that aren't locked stay in their groups as well, only the groupless persons are matched
+	Incremental bool
+	// the rules between the copies of the persons of a matcher with slots, see slotMatcher
+	distinct []*distinct
 }
 
 func NewMatcher(persons []*Person, groups []*Group) *Matcher {
-	return &Matcher{Groups: groups, Persons: persons}
+	return &Matcher{Groups: groups, Persons: persons, RankCosts: LinearRankCosts()}
 }
 
 // Simple helper function to try out many solutions and get the best
 func (m *Matcher) MatchManyAndTakeBest(n int, hardTimeout time.Duration, softTimeout time.Duration) error {
-	matchers, err := m.matchMany(n, hardTimeout, softTimeout)
-	m.takeBest(matchers)
+	opts := DefaultOptions()
+	opts.Tries, opts.HardTimeout, opts.SoftTimeout = n, hardTimeout, softTimeout
+	tries, _, err := m.matchMany(context.Background(), opts)
+	if best := m.takeBest(tries, m.RankCosts); best != nil {
+		m.Apply(best)
+	}
 	return err
 }
 
+// solver that shuffles the persons and runs SmartMatch many times
+type heuristicSolver struct{}
+
+func (heuristicSolver) Name() string {
+	return "heuristic"
+}
+
+func (heuristicSolver) Solve(ctx context.Context, m *Matcher, opts Options) (Assignment, Stats, error) {
+	tries, attempts, err := m.matchMany(ctx, opts)
+	return m.takeBest(tries, opts.RankCosts), Stats{Attempts: attempts}, err
+}
+
+func init() {
+	RegisterSolver(heuristicSolver{})
+}
+
 func (m *Matcher) SmartMatch() bool {
 
 	// insert all persons into their wishes
@@ -32,7 +62,7 @@ func (m *Matcher) SmartMatch() bool {
 	for _, p := range r {
 		worked := false
 		for _, pref := range p.Preferences {
-			if InsertPersonIntoFullGroup(p, pref) {
+			if InsertPersonIntoFullGroup(p, pref, m.RankCosts) {
 				worked = true
 				break
 			}
@@ -46,7 +76,7 @@ func (m *Matcher) SmartMatch() bool {
 }
 
 // insert person into a full group while kicking out others and still keeping the score as high as possible
-func InsertPersonIntoFullGroup(p *Person, g *Group) bool {
+func InsertPersonIntoFullGroup(p *Person, g *Group, rc RankCosts) bool {
 	if len(g.Members) < g.Capacity {
 		g.Members = append(g.Members, p)
 		return true
@@ -56,6 +86,9 @@ func InsertPersonIntoFullGroup(p *Person, g *Group) bool {
 	var score int = -1000000
 	// find person to move to another group
 	for _, candidate := range g.Members {
+		if g.IsLocked(candidate) {
+			continue
+		}
 		for i := g.IndexIn(candidate.Preferences) + 1; i != 0 && i < len(candidate.Preferences); i++ {
 			if len(candidate.Preferences[i].Members) >= candidate.Preferences[i].Capacity {
 				// Don't overfill groups
@@ -63,7 +96,7 @@ func InsertPersonIntoFullGroup(p *Person, g *Group) bool {
 			}
 
 			// score is calculated that empty groups are filled and people get their favorite wishes
-			cScore := candidate.Preferences[i].MinSize - len(candidate.Preferences[i].Members) - i
+			cScore := candidate.Preferences[i].MinSize - len(candidate.Preferences[i].Members) - rc.RankCost(i)
 			if cScore > score {
 				bestCandidate = candidate
 				moveTo = candidate.Preferences[i]
@@ -82,80 +115,188 @@ func InsertPersonIntoFullGroup(p *Person, g *Group) bool {
 	return true
 }
 
-// asynchronously shuffle and match this matcher n times and return the new matchers
+// asynchronously shuffle and match copies of this matcher opts.Tries times and return the found assignments
 // if found any solution, stop calculation at softTimeout or keep going for at least one solution until hardTimeout
-func (m *Matcher) matchMany(n int, hardTimeout time.Duration, softTimeout time.Duration) (matchers []*Matcher, err error) {
-	j, err := ToJSON(m.Groups, m.Persons)
+// the calculation is also stopped when ctx is canceled, opts.Progress is called regularly while running
+func (m *Matcher) matchMany(ctx context.Context, opts Options) (assignments []Assignment, attempts int, err error) {
+	// the copies also contain the members of the groups, which are locked in the copies so SmartMatch leaves them untouched
+	// wishes for groups that aren't part of the matcher (e.g. cancelled ones when rematching) are ignored like by the
+	// other solvers
+	originals := m.allPersons()
+	store := NewJSONStore(m.Groups, originals)
+	for i, p := range store.Persons {
+		prefs := p.Preferences[:0]
+		for _, k := range p.Preferences {
+			if k != -1 {
+				prefs = append(prefs, k)
+			}
+		}
+		store.Persons[i].Preferences = prefs
+	}
+	j, err := json.Marshal(store)
 	if err != nil {
 		log.Fatal(err)
 		err = nil
 	}
-	var wg sync.WaitGroup
-	wg.Add(n)
-	ms := make([]*Matcher, n)
 	start := time.Now()
-	found := false
+	hardCtx, cancel := context.WithTimeout(ctx, opts.HardTimeout)
+	defer cancel()
+
+	// state shared by all goroutines, the result of every try is kept at its index so that the order of the results
+	// doesn't depend on which goroutine is faster
+	var mu sync.Mutex
+	results := make([]Assignment, opts.Tries)
+	found := 0
+	var best Assignment
+	progress := func() Progress {
+		mu.Lock()
+		defer mu.Unlock()
+		return Progress{Attempts: attempts, Found: found, BestQuote: best.quote(opts.RankCosts), Elapsed: time.Since(start)}
+	}
 
 	defer func() {
+		assignments = make([]Assignment, 0, found)
+		for _, a := range results {
+			if a != nil {
+				assignments = append(assignments, a)
+			}
+		}
 		dur := time.Since(start)
-		if dur > hardTimeout {
+		if ctx.Err() == context.Canceled {
+			err = errors.New("canceled")
+		} else if hardCtx.Err() != nil && len(assignments) == 0 {
 			err = errors.New("hardtimeout")
-		}
-		if dur > softTimeout && found {
+		} else if (dur > opts.SoftTimeout || hardCtx.Err() != nil) && len(assignments) > 0 {
 			err = errors.New("softtimeout")
 		}
+		if opts.Progress != nil {
+			opts.Progress(progress())
+		}
 	}()
 
-	for i := range ms {
-		go func(num int) {
+	var wg sync.WaitGroup
+	wg.Add(opts.Tries)
+	for i := 0; i < opts.Tries; i++ {
+		// every try has its own random numbers derived from the seed
+		rnd := newRand(opts.Seed + int64(i))
+		i := i
+		go func() {
 			defer wg.Done()
-			for {
+			for hardCtx.Err() == nil {
+				mu.Lock()
+				if found > 0 && time.Since(start) > opts.SoftTimeout {
+					mu.Unlock()
+					return
+				}
+				attempts++
+				mu.Unlock()
+
 				groups, persons, err := FromJSON(j)
 				if err != nil {
 					log.Fatal(err)
 				}
-				Shuffle(persons)
-				m2 := NewMatcher(persons, groups)
-				if m2.SmartMatch() {
-					ms[num] = m2
-					found = true
-					return
-				}
-				dur := time.Since(start)
-				if dur > hardTimeout {
-					return
+				for _, g := range groups {
+					g.Locked = append([]*Person(nil), g.Members...)
 				}
-				if dur > softTimeout && found {
+				// the copies have the same order as the originals, so only shuffle the order of insertion
+				shuffled := make([]*Person, len(persons))
+				copy(shuffled, persons)
+				Shuffle(shuffled, rnd)
+				m2 := NewMatcher(shuffled, groups)
+				m2.RankCosts = opts.RankCosts
+				if m2.SmartMatch() {
+					a := m.repaired(hardCtx, m.translate(m2, persons, originals), opts.RankCosts, rnd)
+					if a == nil {
+						continue
+					}
+					mu.Lock()
+					results[i] = a
+					found++
+					if best == nil || m.cost(a, opts.RankCosts) < m.cost(best, opts.RankCosts) {
+						best = a
+					}
+					mu.Unlock()
 					return
 				}
 			}
-		}(i)
+		}()
 	}
-	wg.Wait()
-	matchers = make([]*Matcher, 0)
-	for _, m := range ms {
-		if m != nil {
-			matchers = append(matchers, m)
+
+	// report the progress until all goroutines are done
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if opts.Progress != nil {
+				opts.Progress(progress())
+			}
 		}
 	}
-	return
 }
 
-// Use the matcher with the best result from the given slice for the current matcher
-func (m *Matcher) takeBest(tries []*Matcher) {
-	bestQuote := 0.0
-	var bestTry *Matcher
-	for _, try := range tries {
-		_, q := try.CalcQuote()
-		if q > bestQuote {
-			bestQuote = q
-			bestTry = try
+// translates the result of a matched copy (created via ToJSON/FromJSON of the originals) back to the persons and groups
+// of this matcher
+func (m *Matcher) translate(copied *Matcher, copiedPersons, originals []*Person) Assignment {
+	a := make(Assignment)
+	for i, g := range copied.Groups {
+		for _, p := range g.Members {
+			k := p.IndexIn(copiedPersons)
+			// members that were already in the group before are not part of the assignment
+			if k != -1 && originals[k].GetGroup(m.Groups) == nil {
+				a[originals[k]] = m.Groups[i]
+			}
+		}
+	}
+	return a
+}
+
+// returns the assignment if it respects all group sizes and rules, otherwise tries to repair it by a local search
+// SmartMatch doesn't know the rules and doesn't guarantee the minimal sizes, nil is returned if the repair fails
+// or ctx is done before. As SmartMatch doesn't know the friends either, their wishes are improved afterwards.
+func (m *Matcher) repaired(ctx context.Context, a Assignment, rc RankCosts, rnd *rand.Rand) Assignment {
+	s := m.newSearch(a, rc)
+	if violations, _ := s.rate(); violations > 0 && !s.repair(ctx, 20*len(s.movable)+100, rnd) {
+		return nil
+	}
+	if len(s.friendships) > 0 {
+		// only the persons with friends are improved, as they are what SmartMatch doesn't optimize
+		var friends []*Person
+		for _, p := range s.movable {
+			if len(s.friendsOf[p]) > 0 {
+				friends = append(friends, p)
+			}
 		}
+		s.improve(ctx, 20*len(s.movable)+100, friends, false)
 	}
-	if bestTry != nil {
-		copy(m.Groups, bestTry.Groups)
-		copy(m.Persons, bestTry.Persons)
+	return s.assignment()
+}
+
+// returns the assignment improved by changes of all persons including ejection chains that don't add violations, see
+// search.improve
+func (m *Matcher) improved(ctx context.Context, a Assignment, rc RankCosts) Assignment {
+	s := m.newSearch(a, rc)
+	s.improve(ctx, 20*len(s.movable)+100, s.movable, true)
+	return s.assignment()
+}
+
+// returns the assignment with the lowest costs (including split friendships) from the given slice
+func (m *Matcher) takeBest(tries []Assignment, rc RankCosts) Assignment {
+	var best Assignment
+	bestCost := 0
+	for _, try := range tries {
+		if cost := m.cost(try, rc); best == nil || cost < bestCost {
+			best, bestCost = try, cost
+		}
 	}
+	return best
 }
 
 //corrects the given matcher in matter of group length (SmartMatch doesn't take care of minSize)
@@ -195,7 +336,7 @@ func (m *Matcher) getCandidates(preference *Group) (freeC, neededC []*Person) {
 			if m.Groups[group] != preference {
 				for member = range m.Groups[group].Members {
 					if len(m.Groups[group].Members[member].Preferences) > pref {
-						if m.Groups[group].Members[member].Preferences[pref] == preference {
+						if m.Groups[group].Members[member].Preferences[pref] == preference && !m.Groups[group].IsLocked(m.Groups[group].Members[member]) {
 							if m.Groups[group].MinSize >= len(m.Groups[group].Members) {
 								neededC = append(neededC, m.Groups[group].Members[member])
 							} else {
@@ -211,107 +352,56 @@ func (m *Matcher) getCandidates(preference *Group) (freeC, neededC []*Person) {
 }
 
 //checks the matcher for correctness in matter of total, but also group specific person amount
+//with slots, the copy of every person for every slot is checked (see slotMatcher)
 func (m *Matcher) CheckMatcher() (error, string) {
-	var needComma bool = false
-	var errString string
+	if Slots(m.Groups) != nil {
+		return m.checkSlots()
+	}
+	return m.check()
+}
 
-	//check for persons that are already assigned
-	if m.numberAssigned() != 0 {
+func (m *Matcher) check() (error, string) {
+	//check for persons that are already assigned without being locked, unless they are kept in incremental mode
+	if !m.Incremental && m.numberAssigned() != 0 {
 		return errors.New("assigned_persons"), ""
 	}
 
-	//check for group specific person amount
-	// i := range m.Groups not possible because of changing Groups length
-	for i := len(m.Groups) - 1; i >= 0; i-- {
-		//if there are not enough candidates for one group
-		if !enoughCandidates(m.Groups[i], m.Persons) {
-			//create error message
-			if needComma {
-				errString = errString + ", " + m.Groups[i].Name
-			} else {
-				errString = errString + m.Groups[i].Name
-				needComma = true
-			}
-			for j := range m.Persons {
-				//delete equivalent preferences
-				for k := len(m.Persons[j].Preferences) - 1; k >= 0; k-- {
-					if m.Persons[j].Preferences[k] == m.Groups[i] {
-						m.Persons[j].Preferences = append(m.Persons[j].Preferences[:k], m.Persons[j].Preferences[k+1:]...)
-					}
-				}
-				//ceck for persons with no preferences left
-				if len(m.Persons[j].Preferences) < 1 {
-					return errors.New("person_no_pref"), errString
-				}
-			}
-			//and finally delete group
-			m.Groups = append(m.Groups[:i], m.Groups[i+1:]...)
+	//groups with too few candidates are only reported, the following checks are done as if they were removed
+	r := m.suggest()
+	if r != nil {
+		if len(r.Persons) > 0 {
+			return errors.New("person_no_pref"), r.PersonNames(", ")
 		}
-	}
-
-	//check for basic combination problems
-	//the algorithm doesn't detect an error if there are two combinations which necessaryly need space in one group
-	needComma = false
-	var combinations []Combination
-	//get all combinations and subcombinations
-	m.sortByPrefLen() //sort persons by preference length, so that subconfigurations are also put into the main-configuration
-	for i := range m.Persons {
-		if !addToAnyIfFitting(m.Persons[i].Preferences, combinations) {
-			var configuration []Part
-			for j := range m.Persons[i].Preferences {
-				configuration = append(configuration, Part{m.Persons[i].Preferences[j], 1})
+		c := *m
+		c.Groups = make([]*Group, 0, len(m.Groups))
+		for _, g := range m.Groups {
+			if g.IndexIn(r.Groups) == -1 {
+				c.Groups = append(c.Groups, g)
 			}
-			combinations = append(combinations, Combination{1, configuration})
 		}
+		m = &c
 	}
 
-	var foundErr bool
-	//check for overfilled combinations and create error message (more complicated combination errors are not caught)
-	for i := range combinations {
-		var totalCapacity int
-		for j := range combinations[i].Configuration {
-			//the capacity a group adds to the totalCapacity is limited by the larger one of Capacity or CandidateAmount
-			if combinations[i].Configuration[j].Group.Capacity < combinations[i].Configuration[j].CandidateAmount {
-				totalCapacity = totalCapacity + combinations[i].Configuration[j].Group.Capacity
-			} else {
-				totalCapacity = totalCapacity + combinations[i].Configuration[j].CandidateAmount
-			}
-		}
-		if combinations[i].Quantity > totalCapacity { //check if there is enough space in combinations groups and create error message if necessary
-			foundErr = true
-			if needComma {
-				errString = errString + ", " + combinations[i].Configuration[0].Group.Name
-				for j := 1; j < len(combinations[i].Configuration); j++ {
-					errString = errString + "|" + combinations[i].Configuration[j].Group.Name
-				}
-			} else {
-				errString = errString + combinations[i].Configuration[0].Group.Name
-				for j := 1; j < len(combinations[i].Configuration); j++ {
-					errString = errString + "|" + combinations[i].Configuration[j].Group.Name
-				}
-				needComma = true
-			}
-		}
+	//check whether the sizes of the groups can be respected at all, ignoring the rules between persons
+	if c := m.Diagnose(); c != nil {
+		return c, c.String()
 	}
-	if foundErr {
-		return errors.New("combination_overfilled"), errString
+
+	//check the constraints between persons
+	if err, names := m.checkConstraints(); err != nil {
+		return err, names
 	}
 
-	//ceck for total person amount
-	var totalMin, totalCap int
-	for i := range m.Groups {
-		totalMin = totalMin + m.Groups[i].MinSize
-		totalCap = totalCap + m.Groups[i].Capacity
+	//check the balance rules
+	if err, rule := m.checkBalance(); err != nil {
+		return err, rule
 	}
-	if len(m.Persons) < totalMin || len(m.Persons) > totalCap {
-		return errors.New("err_matching_too_few_many"), errString
-	} else {
-		if errString == "" {
-			return nil, errString
-		} else {
-			return errors.New("group_deleted"), errString
-		}
+
+	//the report has to be accepted before matching
+	if r != nil {
+		return errors.New("group_deleted"), r.GroupNames(", ")
 	}
+	return nil, ""
 }
 
 //checks if there are enough persons with the right preference according to the current group
@@ -330,23 +420,24 @@ func enoughCandidates(group *Group, persons []*Person) bool {
 	return false
 }
 
-//return number of assigned persons
+//return number of assigned persons that are not locked
 func (m *Matcher) numberAssigned() (n int) {
 	for i := range m.Groups {
-		n += len(m.Groups[i].Members)
+		n += len(m.Groups[i].Members) - len(m.Groups[i].Locked)
 	}
 	return
 }
 
-// calculate the average preference number every person got and the wish fulfilling quote in percent
+// calculate the average rank costs every person got plus one and the wish fulfilling quote in percent
+// with linear rank costs, the first value is the average preference number
 func (m *Matcher) CalcQuote() (quote, percentage float64) {
 	nQuote := 0
 	nMaxQuote := 0
 	nAssigned := 0
 	for _, g := range m.Groups {
 		for _, p := range g.Members {
-			nQuote += g.IndexIn(p.Preferences)
-			nMaxQuote += len(p.Preferences)
+			nQuote += m.RankCosts.Cost(p, g)
+			nMaxQuote += m.RankCosts.UnlistedCost(p)
 			nAssigned++
 		}
 	}
@@ -371,28 +462,8 @@ func (m *Matcher) getHostGroup(p *Person) *Group {
 }
 
 //get maximum length of a persons Preferences
-func (m *Matcher) getMaxPref() (max int) {
-	for _, person := range m.Persons {
-		if max < len(person.Preferences) {
-			max = len(person.Preferences)
-		}
-	}
-	return
-}
-
-//orders the persons from many to few wishes
-func (m *Matcher) sortByPrefLen() {
-	var persons []*Person
-	currLen := m.getMaxPref()
-	for currLen >= 0 {
-		for i := range m.Persons {
-			if currLen == len(m.Persons[i].Preferences) {
-				persons = append(persons, m.Persons[i])
-			}
-		}
-		currLen--
-	}
-	m.Persons = persons
+func (m *Matcher) getMaxPref() int {
+	return MaxPreferences(m.Persons)
 }
 
 //print groups (testing purpose)
@@ -409,7 +480,11 @@ func (m *Matcher) printMatcher() {
 		fmt.Println("--------------------------------------------------------------------------------------")
 		fmt.Println("Gruppenname: ", m.Groups[i].Name, "\t( ", len(m.Groups[i].Members), " )")
 		for j := 0; j < len(m.Groups[i].Members); j++ {
-			fmt.Println(j, "\t:\t\t", m.Groups[i].Members[j].Name, "\t (", m.Groups[i].Members[j].Preferences[0].Name, ")\t(", m.Groups[i].Members[j].Preferences[1].Name, ")\t(", m.Groups[i].Members[j].Preferences[0].Name, ")")
+			fmt.Print(j, "\t:\t\t", m.Groups[i].Members[j].Name)
+			for _, pref := range m.Groups[i].Members[j].Preferences {
+				fmt.Print("\t(", pref.Name, ")")
+			}
+			fmt.Println()
 		}
 	}
 }
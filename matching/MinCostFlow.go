@@ -0,0 +1,126 @@
+package matching
+
+// distance of unreachable nodes, greater than any cost of a path as the costs of the objectives can be far beyond 32 bit
+const infinity = int(^uint(0) >> 1)
+
+type flowEdge struct {
+	to, rev  int
+	cap      int
+	cost     int
+	flow     int
+	lowerCap int
+}
+
+// network for solving min-cost flow problems with lower bounds on the edges
+type flowNetwork struct {
+	edges  [][]flowEdge
+	excess []int
+}
+
+func newFlowNetwork(nodes int) *flowNetwork {
+	return &flowNetwork{edges: make([][]flowEdge, nodes), excess: make([]int, nodes)}
+}
+
+func (n *flowNetwork) addNode() int {
+	n.edges = append(n.edges, nil)
+	n.excess = append(n.excess, 0)
+	return len(n.edges) - 1
+}
+
+// adds an edge from u to v that has to carry at least lower and at most upper units of flow at the given cost per unit
+// and returns its position in the adjacency list of u
+func (n *flowNetwork) addEdge(u, v, lower, upper, cost int) int {
+	n.edges[u] = append(n.edges[u], flowEdge{to: v, rev: len(n.edges[v]), cap: upper - lower, cost: cost, lowerCap: lower})
+	n.edges[v] = append(n.edges[v], flowEdge{to: u, rev: len(n.edges[u]) - 1, cap: 0, cost: -cost})
+	// the lower bound is sent in advance, the difference has to be balanced by the solver
+	n.excess[v] += lower
+	n.excess[u] -= lower
+	return len(n.edges[u]) - 1
+}
+
+// returns the total flow on the given edge including its lower bound
+func (n *flowNetwork) flowOn(u, i int) int {
+	return n.edges[u][i].flow + n.edges[u][i].lowerCap
+}
+
+// finds a circulation of minimal cost that satisfies all lower bounds and returns false if there is none
+func (n *flowNetwork) minCostCirculation() bool {
+	source := n.addNode()
+	sink := n.addNode()
+	demand := 0
+	for v := range n.excess {
+		if n.excess[v] > 0 {
+			n.addEdge(source, v, 0, n.excess[v], 0)
+			demand += n.excess[v]
+		} else if n.excess[v] < 0 {
+			n.addEdge(v, sink, 0, -n.excess[v], 0)
+		}
+	}
+	sent := n.augment(source, sink, demand)
+
+	// remove the helper nodes again so that the network can be inspected by the caller
+	for v := range n.edges {
+		for i := len(n.edges[v]) - 1; i >= 0; i-- {
+			if n.edges[v][i].to >= source {
+				n.edges[v] = append(n.edges[v][:i], n.edges[v][i+1:]...)
+			}
+		}
+	}
+	n.edges = n.edges[:source]
+	n.excess = n.excess[:source]
+	return sent == demand
+}
+
+// sends up to limit units of flow from s to t along successive shortest paths and returns the amount sent
+func (n *flowNetwork) augment(s, t, limit int) int {
+	sent := 0
+	dist := make([]int, len(n.edges))
+	inQueue := make([]bool, len(n.edges))
+	prevNode := make([]int, len(n.edges))
+	prevEdge := make([]int, len(n.edges))
+	for sent < limit {
+		// Bellman-Ford with a queue, as residual edges can have negative costs
+		for i := range dist {
+			dist[i] = infinity
+			prevNode[i] = -1
+		}
+		dist[s] = 0
+		queue := []int{s}
+		inQueue[s] = true
+		for len(queue) > 0 {
+			u := queue[0]
+			queue = queue[1:]
+			inQueue[u] = false
+			for i, e := range n.edges[u] {
+				if e.cap-e.flow > 0 && dist[u]+e.cost < dist[e.to] {
+					dist[e.to] = dist[u] + e.cost
+					prevNode[e.to] = u
+					prevEdge[e.to] = i
+					if !inQueue[e.to] {
+						queue = append(queue, e.to)
+						inQueue[e.to] = true
+					}
+				}
+			}
+		}
+		if dist[t] == infinity {
+			break
+		}
+
+		// find bottleneck of the path and push flow along it
+		amount := limit - sent
+		for v := t; v != s; v = prevNode[v] {
+			e := n.edges[prevNode[v]][prevEdge[v]]
+			if e.cap-e.flow < amount {
+				amount = e.cap - e.flow
+			}
+		}
+		for v := t; v != s; v = prevNode[v] {
+			e := &n.edges[prevNode[v]][prevEdge[v]]
+			e.flow += amount
+			n.edges[v][e.rev].flow -= amount
+		}
+		sent += amount
+	}
+	return sent
+}
@@ -0,0 +1,203 @@
+package matching
+
+import "testing"
+
+// returns the groups A, B, ... with the given capacities and minimal sizes and the persons a, b, ... with the given
+// wishes as indices of the groups
+func newTestProject(sizes [][2]int, wishes [][]int) ([]*Group, []*Person) {
+	var groups []*Group
+	for i, size := range sizes {
+		groups = append(groups, NewGroup(string(rune('A'+i)), size[0], size[1]))
+	}
+	var persons []*Person
+	for i, w := range wishes {
+		var preferences []*Group
+		for _, k := range w {
+			preferences = append(preferences, groups[k])
+		}
+		persons = append(persons, NewPerson(string(rune('a'+i)), preferences))
+	}
+	return groups, persons
+}
+
+type testEdge struct {
+	u, v, lower, upper, cost int
+}
+
+func TestMinCostCirculation(t *testing.T) {
+	const large = 1 << 20
+	tests := []struct {
+		name     string
+		nodes    int
+		edges    []testEdge
+		feasible bool
+		cost     int
+		flows    []int
+	}{
+		{
+			name:  "cheapest path",
+			nodes: 4,
+			edges: []testEdge{
+				{0, 1, 0, 1, 1}, {1, 3, 0, 1, 0},
+				{0, 2, 0, 1, 3}, {2, 3, 0, 1, 0},
+				{3, 0, 1, 1, 0},
+			},
+			feasible: true, cost: 1, flows: []int{1, 1, 0, 0, 1},
+		},
+		{
+			name:  "both paths",
+			nodes: 4,
+			edges: []testEdge{
+				{0, 1, 0, 1, 1}, {1, 3, 0, 1, 0},
+				{0, 2, 0, 1, 3}, {2, 3, 0, 1, 0},
+				{3, 0, 2, 2, 0},
+			},
+			feasible: true, cost: 4, flows: []int{1, 1, 1, 1, 2},
+		},
+		{
+			name:  "lower bound on expensive path",
+			nodes: 4,
+			edges: []testEdge{
+				{0, 1, 0, 2, 1}, {1, 3, 0, 2, 0},
+				{0, 2, 1, 2, 3}, {2, 3, 0, 2, 0},
+				{3, 0, 2, 2, 0},
+			},
+			feasible: true, cost: 4, flows: []int{1, 1, 1, 1, 2},
+		},
+		{
+			name:  "no flow needed",
+			nodes: 2,
+			edges: []testEdge{
+				{0, 1, 0, 5, 1}, {1, 0, 0, 5, 1},
+			},
+			feasible: true, cost: 0, flows: []int{0, 0},
+		},
+		{
+			name:  "lower bound above capacity of path",
+			nodes: 3,
+			edges: []testEdge{
+				{0, 1, 2, 3, 0}, {1, 2, 0, 1, 0},
+				{2, 0, 0, 3, 0},
+			},
+			feasible: false,
+		},
+		{
+			name:  "lower bound without cycle",
+			nodes: 2,
+			edges: []testEdge{
+				{0, 1, 1, 1, 0},
+			},
+			feasible: false,
+		},
+		{
+			name:  "large costs",
+			nodes: 4,
+			edges: []testEdge{
+				{0, 1, 0, 1, large}, {1, 3, 0, 1, large},
+				{0, 2, 0, 1, 3 * large}, {2, 3, 0, 1, 0},
+				{3, 0, 2, 2, 0},
+			},
+			feasible: true, cost: 5 * large, flows: []int{1, 1, 1, 1, 2},
+		},
+		{
+			name:  "costs beyond 32 bit",
+			nodes: 4,
+			edges: []testEdge{
+				{0, 1, 0, 1, 1 << 40}, {1, 3, 0, 1, 1 << 40},
+				{0, 2, 0, 1, 3 << 40}, {2, 3, 0, 1, 0},
+				{3, 0, 2, 2, 0},
+			},
+			feasible: true, cost: 5 << 40, flows: []int{1, 1, 1, 1, 2},
+		},
+	}
+
+	for _, test := range tests {
+		n := newFlowNetwork(test.nodes)
+		positions := make([]int, len(test.edges))
+		for i, e := range test.edges {
+			positions[i] = n.addEdge(e.u, e.v, e.lower, e.upper, e.cost)
+		}
+		if feasible := n.minCostCirculation(); feasible != test.feasible {
+			t.Errorf("%s: feasible is %v, want %v", test.name, feasible, test.feasible)
+			continue
+		}
+		if !test.feasible {
+			continue
+		}
+		if len(n.edges) != test.nodes {
+			t.Errorf("%s: %d nodes are left, want %d", test.name, len(n.edges), test.nodes)
+		}
+		cost := 0
+		for i, e := range test.edges {
+			flow := n.flowOn(e.u, positions[i])
+			if flow != test.flows[i] {
+				t.Errorf("%s: flow on edge %d is %d, want %d", test.name, i, flow, test.flows[i])
+			}
+			cost += flow * e.cost
+		}
+		if cost != test.cost {
+			t.Errorf("%s: cost is %d, want %d", test.name, cost, test.cost)
+		}
+	}
+}
+
+func TestMatchOptimal(t *testing.T) {
+	tests := []struct {
+		name string
+		// capacity and minimal size of the groups A, B, C
+		sizes [][2]int
+		// wishes of the persons as indices of the groups
+		wishes [][]int
+		// the groups of the persons in the optimal assignment or nil if there is none
+		want []int
+	}{
+		{
+			name:   "first wishes",
+			sizes:  [][2]int{{2, 0}, {2, 0}, {2, 0}},
+			wishes: [][]int{{0, 1}, {1, 0}, {2, 0}},
+			want:   []int{0, 1, 2},
+		},
+		{
+			name:   "capacity",
+			sizes:  [][2]int{{1, 0}, {2, 0}, {2, 0}},
+			wishes: [][]int{{0, 1}, {0}, {1, 2}},
+			want:   []int{1, 0, 1},
+		},
+		{
+			name:   "minimal size",
+			sizes:  [][2]int{{3, 0}, {3, 2}, {3, 0}},
+			wishes: [][]int{{0, 1}, {0, 1}, {2, 0}},
+			want:   []int{1, 1, 2},
+		},
+		{
+			name:   "minimal size not reachable",
+			sizes:  [][2]int{{3, 0}, {3, 3}, {3, 0}},
+			wishes: [][]int{{0, 1}, {0, 1}, {2, 0}},
+		},
+		{
+			name:   "not enough places",
+			sizes:  [][2]int{{1, 0}, {1, 0}, {1, 0}},
+			wishes: [][]int{{0, 1}, {0, 1}, {1, 0}},
+		},
+	}
+
+	for _, test := range tests {
+		groups, persons := newTestProject(test.sizes, test.wishes)
+		err := NewMatcher(persons, groups).MatchOptimal()
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("%s: no error for a project without solution", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		for i, p := range persons {
+			if g := p.GetGroup(groups); g != groups[test.want[i]] {
+				t.Errorf("%s: %s is in %v, want %s", test.name, p.Name, g, groups[test.want[i]].Name)
+			}
+		}
+	}
+}
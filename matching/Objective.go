@@ -0,0 +1,113 @@
+package matching
+
+import (
+	"errors"
+	"math"
+)
+
+// The objective decides which assignment is the best one. "sum" minimizes the sum of the rank costs, "rank_maximal"
+// maximizes the number of persons that get their first wish, then the number that get their second wish and so on,
+// "leximin" minimizes the worst wish any person gets, then the number of persons that get it and so on.
+// All solvers minimize rank costs, so the fairness objectives are expressed by rank costs that grow so fast that no
+// number of better wishes makes up for a single worse one (see objectiveCosts).
+
+// returns an error if the objective is unknown
+func checkObjective(objective string) error {
+	switch objective {
+	case "sum", "rank_maximal", "leximin":
+		return nil
+	}
+	return errors.New("invalid_setting")
+}
+
+// returns the rank costs that the solvers minimize for the objective when matching the given persons and the largest
+// difference of the costs of two following wishes, which is the unit of the temperature of the annealing solver. The
+// costs of moving persons when rematching are in units of getting the second instead of the first wish, split
+// friendships cost as much as a second wish.
+// The costs are powers of a base greater than the number of persons. If the sums of the costs could reach the distance
+// of unreachable nodes in the flow (see infinity) for large projects, a smaller base is taken, so the priorities are
+// only kept approximately.
+func (o Options) objectiveCosts(persons []*Person) (RankCosts, int) {
+	if o.Objective == "sum" || o.Objective == "" {
+		return o.RankCosts, 1
+	}
+	n := len(persons)
+	ranks := MaxPreferences(persons)
+	limit := infinity / (16 * (n + 1))
+	base := n + 1
+	for base > 2 && math.Pow(float64(base), float64(ranks)) > float64(limit) {
+		base--
+	}
+	pow := make([]int, ranks+1)
+	pow[0] = 1
+	for r := 1; r <= ranks; r++ {
+		pow[r] = pow[r-1] * base
+	}
+	costs := make([]int, ranks+1)
+	for r := range costs {
+		if o.Objective == "rank_maximal" {
+			costs[r] = pow[ranks] - pow[ranks-r]
+		} else {
+			costs[r] = pow[r] - 1
+		}
+	}
+	unit, step := 1, 1
+	if ranks > 0 {
+		unit = costs[1] - costs[0]
+	}
+	for r := 1; r <= ranks; r++ {
+		if costs[r]-costs[r-1] > step {
+			step = costs[r] - costs[r-1]
+		}
+	}
+	rc := RankCosts{Curve: "custom", Costs: costs}
+	if extra := o.RankCosts.extra; extra != nil {
+		rc.extra = func(p *Person, g *Group) int {
+			return unit * extra(p, g)
+		}
+	}
+	return rc, step
+}
+
+// Metrics describe how good the assignment of the members of the groups is for every objective
+type Metrics struct {
+	// number of persons that got their first, second, ... wish
+	Got []int
+	// number of persons in groups they didn't wish for
+	Unlisted int
+	// the worst wish any person got starting at 1, 0 if a person got a group it didn't wish for
+	Worst int
+	// number of persons that got the worst wish or a group they didn't wish for
+	WorstCount int
+	// sum of the numbers of the wishes the persons got, a group that wasn't wished for counts as the wish after the
+	// last one of the person
+	Sum int
+}
+
+// calculates the metrics of the current assignment, with slots every group of a person counts
+func (m *Matcher) CalcMetrics() Metrics {
+	metrics := Metrics{Got: make([]int, MaxPreferences(m.allPersons()))}
+	for _, g := range m.Groups {
+		for _, p := range g.Members {
+			rank := p.Rank(g)
+			if rank == -1 {
+				metrics.Unlisted++
+				metrics.Sum += len(p.Preferences) + 1
+				continue
+			}
+			metrics.Got[rank]++
+			metrics.Sum += rank + 1
+		}
+	}
+	if metrics.Unlisted > 0 {
+		metrics.WorstCount = metrics.Unlisted
+		return metrics
+	}
+	for r := len(metrics.Got) - 1; r >= 0; r-- {
+		if metrics.Got[r] > 0 {
+			metrics.Worst, metrics.WorstCount = r+1, metrics.Got[r]
+			break
+		}
+	}
+	return metrics
+}
@@ -0,0 +1,88 @@
+package matching
+
+import (
+	"context"
+	"strconv"
+	"testing"
+)
+
+// 120 persons that all wish for the groups A to E in this order, A can only take half of them
+func objectiveProject() *Matcher {
+	var groups []*Group
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		groups = append(groups, NewGroup(name, 120, 0))
+	}
+	groups[0].Capacity = 60
+	var persons []*Person
+	for i := 0; i < 120; i++ {
+		persons = append(persons, NewPerson("p"+strconv.Itoa(i), groups))
+	}
+	return NewMatcher(persons, groups)
+}
+
+func TestObjectivesLargeProject(t *testing.T) {
+	for _, objective := range []string{"sum", "rank_maximal", "leximin"} {
+		for _, solver := range []string{"exact", "heuristic"} {
+			if solver == "heuristic" && objective == "sum" {
+				// the heuristic doesn't find the optimum of the sum, which has no steep costs that lead it there
+				continue
+			}
+			m := objectiveProject()
+			opts := DefaultOptions()
+			opts.Solver, opts.Objective, opts.Seed, opts.Tries = solver, objective, 1, 5
+			if _, err := m.Solve(context.Background(), opts); err != nil {
+				t.Errorf("%s with %s: %v", objective, solver, err)
+				continue
+			}
+			if got := m.CalcMetrics().Got; got[0] != 60 || got[1] != 60 {
+				t.Errorf("%s with %s: got wishes %v, want 60 first and 60 second wishes", objective, solver, got)
+			}
+		}
+	}
+}
+
+func TestObjectiveCostsBelowInfinity(t *testing.T) {
+	for _, n := range []int{1, 10, 120, 1000, 100000} {
+		m := objectiveProject()
+		persons := make([]*Person, n)
+		for i := range persons {
+			persons[i] = m.Persons[0]
+		}
+		for _, objective := range []string{"rank_maximal", "leximin"} {
+			opts := DefaultOptions()
+			opts.Objective = objective
+			rc, _ := opts.objectiveCosts(persons)
+			for r := 1; r < len(rc.Costs); r++ {
+				if rc.Costs[r] <= rc.Costs[r-1] {
+					t.Errorf("%s for %d persons: costs %v don't grow", objective, n, rc.Costs)
+				}
+			}
+			if last := rc.Costs[len(rc.Costs)-1]; last > infinity/(n+1) {
+				t.Errorf("%s for %d persons: cost %d of the last wish can overflow the sum of all costs", objective, n, last)
+			}
+		}
+	}
+}
+
+func TestCalcMetrics(t *testing.T) {
+	m := objectiveProject()
+	a, b, e := m.Groups[0], m.Groups[1], m.Groups[4]
+	a.Members = m.Persons[:2]
+	b.Members = m.Persons[2:3]
+	e.Members = m.Persons[3:5]
+	metrics := m.CalcMetrics()
+	if metrics.Got[0] != 2 || metrics.Got[1] != 1 || metrics.Got[4] != 2 {
+		t.Errorf("got wishes %v, want 2 first, 1 second and 2 fifth wishes", metrics.Got)
+	}
+	if metrics.Worst != 5 || metrics.WorstCount != 2 || metrics.Sum != 2*1+2+2*5 || metrics.Unlisted != 0 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+
+	other := NewGroup("F", 10, 0)
+	other.Members = m.Persons[5:6]
+	m.Groups = append(m.Groups, other)
+	metrics = m.CalcMetrics()
+	if metrics.Unlisted != 1 || metrics.Worst != 0 || metrics.WorstCount != 1 {
+		t.Errorf("a person in a group it didn't wish for isn't the worst off: %+v", metrics)
+	}
+}
@@ -0,0 +1,261 @@
+package matching
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// solver that finds a provably optimal assignment with respect to the rank costs and friend wishes
+type exactSolver struct{}
+
+func (exactSolver) Name() string {
+	return "exact"
+}
+
+func (exactSolver) Solve(ctx context.Context, m *Matcher, opts Options) (Assignment, Stats, error) {
+	if ctx.Err() != nil {
+		return nil, Stats{}, errors.New("canceled")
+	}
+	start := time.Now()
+	hardCtx, cancel := context.WithTimeout(ctx, opts.HardTimeout)
+	defer cancel()
+	m2 := *m
+	m2.RankCosts = opts.RankCosts
+	report := func(nodes, found int, best Assignment) {
+		if opts.Progress != nil {
+			opts.Progress(Progress{Attempts: nodes, Found: found, BestQuote: best.quote(opts.RankCosts), Elapsed: time.Since(start)})
+		}
+	}
+	a, nodes, err := m2.optimalAssignment(hardCtx, opts.SoftTimeout, newRand(opts.Seed), report)
+	if err != nil && err.Error() != "no_solution" && ctx.Err() == context.Canceled {
+		err = errors.New("canceled")
+	}
+	return a, Stats{Attempts: nodes}, err
+}
+
+func init() {
+	RegisterSolver(exactSolver{})
+}
+
+// Assigns all groupless persons optimally, see optimalAssignment
+func (m *Matcher) MatchOptimal() error {
+	a, _, err := m.optimalAssignment(context.Background(), 0, newRand(0), nil)
+	if err != nil {
+		return err
+	}
+	m.Apply(a)
+	return nil
+}
+
+// a decision of the branch and bound search whether a person is in a group, linked to the decisions made before
+type decision struct {
+	parent *decision
+	p      *Person
+	g      *Group
+	in     bool
+}
+
+// a node of the branch and bound search with the cheapest assignment that respects its decisions, its cost is a lower
+// bound of all assignments below the node
+type node struct {
+	decision *decision
+	forced   map[*Person]*Group
+	a        Assignment
+	cost     int
+}
+
+// Finds an assignment of all groupless persons so that the sum of their rank costs (as reported by CalcQuote) and the
+// costs of split friendships is minimal while no rule is violated. Ignoring the rules, the problem is a min-cost flow
+// (see flowAssignment). The flow is used as lower bound of a branch and bound search that decides whether a person is
+// in a group, until no rule is violated and no split friendship can be joined anymore.
+// The search stops early when ctx is done or after softTimeout (if not 0) if a solution was found, report is called
+// regularly with the number of searched nodes, found solutions and the best assignment.
+// If the search is stopped early, the error is "softtimeout" with the best assignment found so far or "hardtimeout".
+// The random numbers for the first solution are taken from rnd.
+func (m *Matcher) optimalAssignment(ctx context.Context, softTimeout time.Duration, rnd *rand.Rand, report func(nodes, found int, best Assignment)) (best Assignment, nodes int, err error) {
+	r := GetGrouplessPersons(m.Persons, m.Groups)
+	rules := m.rules()
+	friendships := m.friendships()
+	fixedGroup := make(map[*Person]*Group)
+	for _, g := range m.Groups {
+		for _, p := range g.Members {
+			fixedGroup[p] = g
+		}
+	}
+
+	// solves the flow for the decisions
+	evaluate := func(d *decision) *node {
+		n := &node{decision: d, forced: make(map[*Person]*Group)}
+		forbidden := make(map[*Person][]*Group)
+		for ; d != nil; d = d.parent {
+			if d.in {
+				n.forced[d.p] = d.g
+			} else {
+				forbidden[d.p] = append(forbidden[d.p], d.g)
+			}
+		}
+		a, ok := m.flowAssignment(r, func(p *Person, g *Group) bool {
+			if n.forced[p] != nil {
+				return n.forced[p] == g
+			}
+			return g.IndexIn(forbidden[p]) == -1
+		})
+		if !ok {
+			return nil
+		}
+		// friendships between fixed persons are split in every assignment below the node
+		n.a, n.cost = a, a.cost(m.RankCosts)
+		n.cost += m.RankCosts.FriendCost() * splitFriendships(friendships, func(p *Person) *Group {
+			if g := fixedGroup[p]; g != nil {
+				return g
+			}
+			return n.forced[p]
+		})
+		return n
+	}
+
+	start, lastReport := time.Now(), time.Now()
+	found, bestCost := 0, 0
+	root := evaluate(nil)
+	if root == nil {
+		return nil, 1, errors.New("no_solution")
+	}
+	// a repaired flow is a good first solution to prune the search with
+	if a := m.repaired(ctx, root.a, m.RankCosts, rnd); a != nil {
+		best, bestCost = a, m.cost(a, m.RankCosts)
+		found++
+	}
+	stack := []*node{root}
+	for len(stack) > 0 && ctx.Err() == nil && (best == nil || softTimeout == 0 || time.Since(start) < softTimeout) {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		nodes++
+		if report != nil && time.Since(lastReport) > progressInterval {
+			report(nodes, found, best)
+			lastReport = time.Now()
+		}
+		if best != nil && n.cost >= bestCost {
+			continue
+		}
+
+		groupOf := func(p *Person) *Group {
+			if g := fixedGroup[p]; g != nil {
+				return g
+			}
+			return n.a[p]
+		}
+		fixed := func(p *Person) bool {
+			return fixedGroup[p] != nil || n.forced[p] != nil
+		}
+		var violated rule
+		for _, rule := range rules {
+			if rule.violations(groupOf) > 0 {
+				violated = rule
+				break
+			}
+		}
+		var p *Person
+		var g *Group
+		if violated != nil {
+			p, g = violated.branch(groupOf, fixed)
+		} else {
+			// the node is a solution, but splitting less friendships might be worth higher rank costs
+			cost := n.a.cost(m.RankCosts) + m.RankCosts.FriendCost()*splitFriendships(friendships, groupOf)
+			if best == nil || cost < bestCost {
+				best, bestCost = n.a, cost
+				found++
+			}
+			p, g = branchFriendships(friendships, groupOf, fixed)
+		}
+		if p == nil {
+			continue
+		}
+
+		// search the cheaper child first
+		var children []*node
+		for _, in := range []bool{true, false} {
+			child := evaluate(&decision{n.decision, p, g, in})
+			if child != nil && (best == nil || child.cost < bestCost) {
+				children = append(children, child)
+			}
+		}
+		if len(children) == 2 && children[0].cost < children[1].cost {
+			children[0], children[1] = children[1], children[0]
+		}
+		stack = append(stack, children...)
+	}
+
+	if report != nil {
+		report(nodes, found, best)
+	}
+	if len(stack) > 0 {
+		if best == nil {
+			return nil, nodes, errors.New("hardtimeout")
+		}
+		return best, nodes, errors.New("softtimeout")
+	}
+	if best == nil {
+		return nil, nodes, errors.New("no_solution")
+	}
+	return best, nodes, nil
+}
+
+// Finds the assignment of the given persons with minimal rank costs that respects the group sizes, but no other rules.
+// Persons can only be assigned to their preferences for which allowed returns true.
+// The problem is modeled as a min-cost flow: every person sends exactly one unit of flow through one of its preferences
+// to the group, every group has to receive at least MinSize and at most Capacity units including its current members.
+func (m *Matcher) flowAssignment(r []*Person, allowed func(p *Person, g *Group) bool) (Assignment, bool) {
+	const source, sink = 0, 1
+	n := newFlowNetwork(2 + len(r) + len(m.Groups))
+	personNode := func(i int) int { return 2 + i }
+	groupNode := func(i int) int { return 2 + len(r) + i }
+
+	// remember the edges from persons to groups to read the assignment afterwards
+	type prefEdge struct {
+		node, edge int
+		group      *Group
+	}
+	prefEdges := make([][]prefEdge, len(r))
+
+	for i, p := range r {
+		n.addEdge(source, personNode(i), 1, 1, 0)
+		for _, pref := range p.Preferences {
+			j := pref.IndexIn(m.Groups)
+			if j == -1 || !allowed(p, pref) {
+				continue
+			}
+			e := n.addEdge(personNode(i), groupNode(j), 0, 1, m.RankCosts.Cost(p, pref))
+			prefEdges[i] = append(prefEdges[i], prefEdge{personNode(i), e, pref})
+		}
+	}
+	for j, g := range m.Groups {
+		// current members already occupy some of the places
+		lower := g.MinSize - len(g.Members)
+		if lower < 0 {
+			lower = 0
+		}
+		upper := g.Capacity - len(g.Members)
+		if upper < lower {
+			return nil, false
+		}
+		n.addEdge(groupNode(j), sink, lower, upper, 0)
+	}
+	n.addEdge(sink, source, 0, len(r), 0)
+
+	if !n.minCostCirculation() {
+		return nil, false
+	}
+
+	a := make(Assignment)
+	for i, p := range r {
+		for _, e := range prefEdges[i] {
+			if n.flowOn(e.node, e.edge) > 0 {
+				a[p] = e.group
+				break
+			}
+		}
+	}
+	return a, true
+}
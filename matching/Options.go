@@ -0,0 +1,180 @@
+package matching
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Options of a project that influence how it is matched
+type Options struct {
+	Solver      string
+	Tries       int
+	HardTimeout time.Duration
+	SoftTimeout time.Duration
+	RankCosts   RankCosts
+	// cost of moving an assigned person to another group when rematching, in units of rank costs
+	MoveCost int
+	// time budget of the annealing solver, in which the temperature is lowered from StartTemperature (in units of
+	// rank costs) by the cooling schedule "exponential" or "linear"
+	AnnealTime       time.Duration
+	StartTemperature float64
+	Cooling          string
+	// length of the cooling schedule in steps, 0 to measure it for AnnealTime at the start of every run
+	AnnealSteps int
+	// seed of the random numbers, 0 for a new seed in every run
+	Seed int64
+	// which assignment is the best one: "sum", "rank_maximal" or "leximin" (see objectiveCosts)
+	Objective string
+
+	// improves the assignment found by the solver by a local search, not stored in project files
+	Improve bool
+	// called regularly while matching, not stored in project files
+	Progress func(Progress)
+}
+
+func DefaultOptions() Options {
+	return Options{Solver: "heuristic", Tries: 50, HardTimeout: time.Minute, SoftTimeout: 10 * time.Second, RankCosts: LinearRankCosts(), MoveCost: 2,
+		AnnealTime: 5 * time.Second, StartTemperature: 2, Cooling: "exponential", Objective: "sum"}
+}
+
+// sets an option by its name as used in project files
+func (o *Options) Set(key, value string) error {
+	var err error
+	switch key {
+	case "solver":
+		if GetSolver(value) == nil {
+			return errors.New("solver_not_found")
+		}
+		o.Solver = value
+	case "tries":
+		o.Tries, err = strconv.Atoi(value)
+		if err == nil && o.Tries < 1 {
+			err = errors.New("invalid_setting")
+		}
+	case "hard_timeout":
+		o.HardTimeout, err = time.ParseDuration(value)
+	case "soft_timeout":
+		o.SoftTimeout, err = time.ParseDuration(value)
+	case "rank_costs":
+		unlisted, friend := o.RankCosts.Unlisted, o.RankCosts.Friend
+		o.RankCosts, err = ParseRankCosts(value)
+		o.RankCosts.Unlisted, o.RankCosts.Friend = unlisted, friend
+		if err != nil {
+			return err
+		}
+	case "unlisted_cost":
+		o.RankCosts.Unlisted, err = strconv.Atoi(value)
+		if err == nil && o.RankCosts.Unlisted < 0 {
+			err = errors.New("invalid_setting")
+		}
+	case "friend_cost":
+		o.RankCosts.Friend, err = strconv.Atoi(value)
+		if err == nil && o.RankCosts.Friend < 0 {
+			err = errors.New("invalid_setting")
+		}
+	case "move_cost":
+		o.MoveCost, err = strconv.Atoi(value)
+		if err == nil && o.MoveCost < 0 {
+			err = errors.New("invalid_setting")
+		}
+	case "anneal_time":
+		o.AnnealTime, err = time.ParseDuration(value)
+		if err == nil && o.AnnealTime <= 0 {
+			err = errors.New("invalid_setting")
+		}
+		// the steps are measured again for the new time
+		o.AnnealSteps = 0
+	case "anneal_steps":
+		o.AnnealSteps, err = strconv.Atoi(value)
+		if err == nil && o.AnnealSteps < 0 {
+			err = errors.New("invalid_setting")
+		}
+	case "seed":
+		o.Seed, err = strconv.ParseInt(value, 10, 64)
+	case "objective":
+		if err := checkObjective(value); err != nil {
+			return err
+		}
+		o.Objective = value
+	case "start_temperature":
+		o.StartTemperature, err = strconv.ParseFloat(value, 64)
+		if err == nil && !(o.StartTemperature > 0) {
+			err = errors.New("invalid_setting")
+		}
+	case "cooling":
+		if value != "exponential" && value != "linear" {
+			return errors.New("invalid_setting")
+		}
+		o.Cooling = value
+	default:
+		return errors.New("unknown_setting")
+	}
+	if err != nil {
+		return errors.New("invalid_setting")
+	}
+	return nil
+}
+
+// sets all options of the map in the order of AllPairs, as some options depend on others (e.g. anneal_time resets
+// anneal_steps)
+func (o *Options) SetMap(values map[string]string) error {
+	known := 0
+	for _, pair := range o.AllPairs() {
+		if value, ok := values[pair[0]]; ok {
+			known++
+			if err := o.Set(pair[0], value); err != nil {
+				return err
+			}
+		}
+	}
+	if known < len(values) {
+		return errors.New("unknown_setting")
+	}
+	return nil
+}
+
+// returns all options as key/value pairs that can be passed to Set
+func (o *Options) AllPairs() [][2]string {
+	return [][2]string{
+		{"solver", o.Solver},
+		{"tries", strconv.Itoa(o.Tries)},
+		{"hard_timeout", o.HardTimeout.String()},
+		{"soft_timeout", o.SoftTimeout.String()},
+		{"rank_costs", o.RankCosts.String()},
+		{"unlisted_cost", strconv.Itoa(o.RankCosts.Unlisted)},
+		{"friend_cost", strconv.Itoa(o.RankCosts.Friend)},
+		{"move_cost", strconv.Itoa(o.MoveCost)},
+		{"anneal_time", o.AnnealTime.String()},
+		{"start_temperature", strconv.FormatFloat(o.StartTemperature, 'g', -1, 64)},
+		{"cooling", o.Cooling},
+		{"anneal_steps", strconv.Itoa(o.AnnealSteps)},
+		{"seed", strconv.FormatInt(o.Seed, 10)},
+		{"objective", o.Objective},
+	}
+}
+
+// stores the seed and the length of the cooling schedule of a run in the options, so matching with them again
+// reproduces the result as long as the run wasn't stopped by a timeout
+func (o *Options) Record(stats Stats) {
+	if stats.Seed == 0 {
+		return
+	}
+	o.Seed = stats.Seed
+	if stats.AnnealSteps > 0 {
+		o.AnnealSteps = stats.AnnealSteps
+	}
+}
+
+// returns all options that differ from the defaults as key/value pairs that can be passed to Set
+func (o *Options) Pairs() [][2]string {
+	def := DefaultOptions()
+	defPairs := def.AllPairs()
+	var pairs [][2]string
+	for i, pair := range o.AllPairs() {
+		if pair != defPairs[i] {
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
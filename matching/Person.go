@@ -9,6 +9,12 @@ import (
 type Person struct {
 	Name        string
 	Preferences []*Group
+	// free key/value pairs like class or level, used by balance rules
+	Attributes map[string]string
+	// persons this person wants to be in the same group with
+	Friends []*Person
+	// the person this is a copy of for a single slot, see slotMatcher
+	original *Person
 }
 
 func NewPerson(name string, preferences []*Group) *Person {
@@ -28,6 +34,26 @@ func (value *Person) IndexIn(slice []*Person) int {
 	return -1
 }
 
+// returns the index of the wish for the group, -1 if the person didn't wish for it. With slots, the wishes are for
+// the names of the groups and count in every slot.
+func (p *Person) Rank(g *Group) int {
+	if p.original != nil {
+		p = p.original
+		for i, pref := range p.Preferences {
+			if pref.Name == g.Name {
+				return i
+			}
+		}
+		return -1
+	}
+	for i, pref := range p.Preferences {
+		if pref == g || (pref.Slot != "" || g.Slot != "") && pref.Name == g.Name {
+			return i
+		}
+	}
+	return -1
+}
+
 func (p *Person) GetGroup(groups []*Group) *Group {
 	for _, g := range groups {
 		if p.IndexIn(g.Members) != -1 {
@@ -37,9 +63,10 @@ func (p *Person) GetGroup(groups []*Group) *Group {
 	return nil
 }
 
-func Shuffle(a []*Person) {
+// shuffles the persons with the given source of random numbers, so the order can be reproduced
+func Shuffle(a []*Person, r *rand.Rand) {
 	for i := range a {
-		j := rand.Intn(i + 1)
+		j := r.Intn(i + 1)
 		a[i], a[j] = a[j], a[i]
 	}
 }
@@ -63,6 +90,16 @@ func Sort(s []*Person) {
 	sort.Sort(t)
 }
 
+// returns the length of the longest preference list of the given persons
+func MaxPreferences(persons []*Person) (max int) {
+	for _, person := range persons {
+		if max < len(person.Preferences) {
+			max = len(person.Preferences)
+		}
+	}
+	return
+}
+
 func FindPerson(name string, persons []*Person) *Person {
 	for i := 0; i < len(persons); i++ {
 		if persons[i].Name == name {
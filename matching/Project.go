@@ -0,0 +1,16 @@
+package matching
+
+// Project contains everything that is stored in a project file
+type Project struct {
+	Groups      []*Group
+	Persons     []*Person
+	Constraints []*Constraint
+	Balance     []*BalanceRule
+	Options     Options
+	// free key/value pairs describing the project, e.g. its title or author
+	Metadata map[string]string
+}
+
+func NewProject(groups []*Group, persons []*Person) *Project {
+	return &Project{Groups: groups, Persons: persons, Options: DefaultOptions()}
+}
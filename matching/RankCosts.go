@@ -0,0 +1,117 @@
+package matching
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// RankCosts define how bad it is for a person to be assigned to a certain preference.
+// The zero value uses linear costs, so that the n-th preference costs n-1.
+type RankCosts struct {
+	// "linear", "exponential" or "custom"
+	Curve string
+	// costs of the preferences for the custom curve, further preferences continue with the last step
+	Costs []int
+	// cost of being in a group that is not a preference at all, 0 to continue the curve after the last preference
+	Unlisted int
+	// cost of every friend wish that isn't fulfilled, 0 for the cost of a second preference
+	Friend int
+	// additional cost of a person in a group, e.g. for moving it away from its group when rematching
+	extra func(p *Person, g *Group) int
+}
+
+func LinearRankCosts() RankCosts {
+	return RankCosts{Curve: "linear"}
+}
+
+// parses the curve of the rank costs: "linear", "exponential" or a comma separated list of costs
+func ParseRankCosts(str string) (RankCosts, error) {
+	switch str {
+	case "", "linear":
+		return LinearRankCosts(), nil
+	case "exponential":
+		return RankCosts{Curve: "exponential"}, nil
+	}
+	rc := RankCosts{Curve: "custom"}
+	for _, s := range strings.Split(str, ",") {
+		c, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || c < 0 || (len(rc.Costs) > 0 && c < rc.Costs[len(rc.Costs)-1]) {
+			return RankCosts{}, errors.New("invalid_rank_costs")
+		}
+		rc.Costs = append(rc.Costs, c)
+	}
+	return rc, nil
+}
+
+// returns the curve in the syntax of ParseRankCosts
+func (rc RankCosts) String() string {
+	if rc.Curve != "custom" {
+		if rc.Curve == "" {
+			return "linear"
+		}
+		return rc.Curve
+	}
+	s := make([]string, len(rc.Costs))
+	for i, c := range rc.Costs {
+		s[i] = strconv.Itoa(c)
+	}
+	return strings.Join(s, ",")
+}
+
+// cost of the preference with the given index (starting at 0)
+func (rc RankCosts) RankCost(rank int) int {
+	switch rc.Curve {
+	case "exponential":
+		if rank > 30 {
+			rank = 30
+		}
+		return 1<<uint(rank) - 1
+	case "custom":
+		if len(rc.Costs) == 0 {
+			return rank
+		}
+		if rank < len(rc.Costs) {
+			return rc.Costs[rank]
+		}
+		step := 1
+		if len(rc.Costs) > 1 && rc.Costs[len(rc.Costs)-1]-rc.Costs[len(rc.Costs)-2] > step {
+			step = rc.Costs[len(rc.Costs)-1] - rc.Costs[len(rc.Costs)-2]
+		}
+		return rc.Costs[len(rc.Costs)-1] + step*(rank-len(rc.Costs)+1)
+	default:
+		return rank
+	}
+}
+
+// cost of the given person being in the given group
+func (rc RankCosts) Cost(p *Person, g *Group) int {
+	extra := 0
+	if rc.extra != nil {
+		extra = rc.extra(p, g)
+	}
+	rank := p.Rank(g)
+	if rank == -1 {
+		return rc.UnlistedCost(p) + extra
+	}
+	return rc.RankCost(rank) + extra
+}
+
+// cost of a person not being in the same group as one of its friends
+func (rc RankCosts) FriendCost() int {
+	if rc.Friend > 0 {
+		return rc.Friend
+	}
+	return rc.RankCost(1)
+}
+
+// cost of the given person being in a group it didn't wish for, this is the worst possible cost for the person
+func (rc RankCosts) UnlistedCost(p *Person) int {
+	if rc.Unlisted > 0 {
+		return rc.Unlisted
+	}
+	if p.original != nil {
+		p = p.original
+	}
+	return rc.RankCost(len(p.Preferences))
+}
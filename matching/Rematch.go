@@ -0,0 +1,136 @@
+package matching
+
+import (
+	"context"
+	"sort"
+)
+
+// Move describes a person that got another group by Rematch. Reason is the key of the localized explanation:
+// "moved_cancelled" if its group was cancelled, "moved_better_wish" if it got a better wish in a place that became
+// free, "moved_make_room" if it had to leave its group so that the group sizes and rules are respected.
+type Move struct {
+	Person   *Person
+	From, To *Group
+	Reason   string
+}
+
+// Matches the persons again after late registrations or cancelled groups while moving as few assigned persons as
+// possible. The current members are the baseline: every unlocked member of a group it wished for is matched again
+// together with the groupless persons, and opts.MoveCost is added to its costs in every group but its own. Members of
+// the cancelled groups always have to move, the cancelled groups are left empty and should be removed afterwards
+// (e.g. by Report.Apply). Other members stay where they are like in incremental mode.
+// Returns the moved persons ordered by the groups they were moved from. If the persons can't be matched, the matcher
+// is left unchanged and the error is returned, CheckRematch gives the details.
+func (m *Matcher) Rematch(ctx context.Context, opts Options, cancelled []*Group) ([]Move, Stats, error) {
+	c, before, restore := m.rematcher(cancelled)
+	if err, _ := c.CheckMatcher(); err != nil {
+		restore()
+		return nil, Stats{}, err
+	}
+
+	opts.RankCosts.extra = moveCosts(before, opts.MoveCost)
+	opts.Improve = true
+	stats, err := c.Solve(ctx, opts)
+	if err != nil && err.Error() != "softtimeout" {
+		restore()
+		return nil, stats, err
+	}
+
+	return m.moves(c.Persons, before, cancelled), stats, err
+}
+
+// returns the persons that aren't in their groups before anymore ordered by these groups
+func (m *Matcher) moves(persons []*Person, before map[*Person][]*Group, cancelled []*Group) []Move {
+	var moves []Move
+	for _, p := range persons {
+		for _, from := range before[p] {
+			to := p.GroupInSlot(m.Groups, from.Slot)
+			if to == from {
+				continue
+			}
+			mv := Move{Person: p, From: from, To: to, Reason: "moved_make_room"}
+			if from.IndexIn(cancelled) != -1 {
+				mv.Reason = "moved_cancelled"
+			} else if to != nil && p.Rank(to) != -1 && p.Rank(to) < p.Rank(from) {
+				mv.Reason = "moved_better_wish"
+			}
+			moves = append(moves, mv)
+		}
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].From.IndexIn(m.Groups) < moves[j].From.IndexIn(m.Groups)
+	})
+	return moves
+}
+
+// checks whether the persons can be matched again by Rematch like CheckMatcher, the matcher is left unchanged
+func (m *Matcher) CheckRematch(cancelled []*Group) (error, string) {
+	c, _, restore := m.rematcher(cancelled)
+	defer restore()
+	return c.CheckMatcher()
+}
+
+// takes the members that are matched again by Rematch out of their groups and returns the matcher for them without
+// the cancelled groups, the groups of the taken out members and a function that puts them back
+func (m *Matcher) rematcher(cancelled []*Group) (*Matcher, map[*Person][]*Group, func()) {
+	before := make(map[*Person][]*Group)
+	members := make([][]*Person, len(m.Groups))
+	locked := make([][]*Person, len(m.Groups))
+	persons := append([]*Person(nil), m.Persons...)
+	for i, g := range m.Groups {
+		members[i], locked[i] = g.Members, g.Locked
+		isCancelled := g.IndexIn(cancelled) != -1
+		kept := make([]*Person, 0, len(g.Members))
+		for _, p := range g.Members {
+			// members in groups they didn't wish for can't be matched into them again
+			if !isCancelled && (g.IsLocked(p) || p.Rank(g) == -1) {
+				kept = append(kept, p)
+				continue
+			}
+			if before[p] == nil && p.IndexIn(persons) == -1 {
+				persons = append(persons, p)
+			}
+			before[p] = append(before[p], g)
+		}
+		g.Members = kept
+		if isCancelled {
+			g.Locked = nil
+		}
+	}
+	restore := func() {
+		for i, g := range m.Groups {
+			g.Members, g.Locked = members[i], locked[i]
+		}
+	}
+
+	c := *m
+	c.Persons, c.Groups, c.Balance = persons, make([]*Group, 0, len(m.Groups)), nil
+	c.Incremental = true
+	for _, g := range m.Groups {
+		if g.IndexIn(cancelled) == -1 {
+			c.Groups = append(c.Groups, g)
+		}
+	}
+	for _, b := range m.Balance {
+		if b.Group == nil || b.Group.IndexIn(cancelled) == -1 {
+			c.Balance = append(c.Balance, b)
+		}
+	}
+	return &c, before, restore
+}
+
+// returns the extra rank costs of a person in a group that isn't its group before in the same slot
+// with slots, the costs are also given for the copies of the persons and groups (see slotMatcher)
+func moveCosts(before map[*Person][]*Group, cost int) func(p *Person, g *Group) int {
+	return func(p *Person, g *Group) int {
+		if p.original != nil {
+			p = p.original
+		}
+		for _, b := range before[p] {
+			if b.Slot == g.Slot && b.Name != g.Name {
+				return cost
+			}
+		}
+		return 0
+	}
+}
@@ -0,0 +1,110 @@
+package matching
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// returns the moves as "person from->to (reason)"
+func describeMoves(moves []Move) string {
+	var s []string
+	for _, mv := range moves {
+		to := ""
+		if mv.To != nil {
+			to = mv.To.Name
+		}
+		s = append(s, mv.Person.Name+" "+mv.From.Name+"->"+to+" ("+mv.Reason+")")
+	}
+	return strings.Join(s, ", ")
+}
+
+func TestRematch(t *testing.T) {
+	tests := []struct {
+		name     string
+		moveCost int
+		// index of the cancelled group, -1 for none
+		cancelled int
+		// the groups of a, b, c and d afterwards
+		want  string
+		moves string
+	}{
+		{
+			name:      "a high move cost keeps the assignment",
+			moveCost:  100,
+			cancelled: -1,
+			want:      "B B C C",
+		},
+		{
+			name:      "without move cost a better wish is taken",
+			moveCost:  0,
+			cancelled: -1,
+			want:      "A B C C",
+			moves:     "a B->A (moved_better_wish)",
+		},
+		{
+			name:      "members of cancelled groups move",
+			moveCost:  100,
+			cancelled: 2,
+			want:      "B B A A",
+			moves:     "d C->A (moved_cancelled)",
+		},
+	}
+
+	for _, solver := range SolverNames() {
+		for _, test := range tests {
+			// a and b are in their second wish B, but b is locked there, c registered late
+			groups, persons := newTestProject([][2]int{{2, 0}, {2, 0}, {2, 0}},
+				[][]int{{0, 1}, {0, 1}, {2, 0}, {2, 0}})
+			a, b, d := persons[0], persons[1], persons[3]
+			groups[1].Members = []*Person{a, b}
+			groups[1].SetLocked(b, true)
+			groups[2].Members = []*Person{d}
+			var cancelled []*Group
+			if test.cancelled != -1 {
+				cancelled = []*Group{groups[test.cancelled]}
+			}
+
+			m := NewMatcher(GetGrouplessPersons(persons, groups), groups)
+			opts := testOptions(solver)
+			opts.MoveCost = test.moveCost
+			moves, _, err := m.Rematch(context.Background(), opts, cancelled)
+			if err != nil {
+				t.Errorf("%s, %s: %v", solver, test.name, err)
+				continue
+			}
+			var got []string
+			for _, p := range persons {
+				if g := p.GetGroup(groups); g != nil {
+					got = append(got, g.Name)
+				} else {
+					got = append(got, "-")
+				}
+			}
+			if strings.Join(got, " ") != test.want || describeMoves(moves) != test.moves {
+				t.Errorf("%s, %s: groups %v with moves %s, want %s with moves %s", solver, test.name, got, describeMoves(moves), test.want, test.moves)
+			}
+			if !groups[1].IsLocked(b) {
+				t.Errorf("%s, %s: b isn't locked anymore", solver, test.name)
+			}
+		}
+	}
+}
+
+// a rematch that isn't possible leaves the project unchanged
+func TestRematchInfeasible(t *testing.T) {
+	groups, persons := newTestProject([][2]int{{1, 0}, {1, 0}}, [][]int{{0}, {1}, {1}})
+	a, b := persons[0], persons[1]
+	groups[0].Members = []*Person{a}
+	groups[1].Members = []*Person{b}
+	m := NewMatcher(GetGrouplessPersons(persons, groups), groups)
+	if err, _ := m.CheckRematch(nil); err == nil {
+		t.Fatal("CheckRematch finds no error")
+	}
+	if _, _, err := m.Rematch(context.Background(), testOptions("exact"), nil); err == nil {
+		t.Fatal("Rematch finds no error")
+	}
+	if a.GetGroup(groups) != groups[0] || b.GetGroup(groups) != groups[1] || len(groups[0].Members)+len(groups[1].Members) != 2 {
+		t.Errorf("the groups were changed: %v", groups)
+	}
+}
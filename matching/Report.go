@@ -0,0 +1,101 @@
+package matching
+
+import "strings"
+
+// Report lists the changes that are necessary before matching: groups without members that can't be built because
+// too few persons wished for them, and the persons that would have no wish left without these groups. CheckMatcher
+// only reports them, the project is changed when the user accepts the report.
+type Report struct {
+	Groups  []*Group
+	Persons []*Person
+}
+
+// returns the changes suggested for the matcher, nil if there are none
+// with slots, the groups are checked for the copies of the persons in their slot (see slotMatcher)
+func (m *Matcher) Suggest() *Report {
+	if Slots(m.Groups) == nil {
+		return m.suggest()
+	}
+	s := m.slotted()
+	r := s.suggest()
+	if r == nil {
+		return nil
+	}
+	ret := &Report{}
+	for _, g := range r.Groups {
+		ret.Groups = append(ret.Groups, s.group[g])
+	}
+	for _, c := range r.Persons {
+		if s.person[c].IndexIn(ret.Persons) == -1 {
+			ret.Persons = append(ret.Persons, s.person[c])
+		}
+	}
+	return ret
+}
+
+func (m *Matcher) suggest() *Report {
+	r := &Report{}
+	for _, g := range m.Groups {
+		//groups with members are kept
+		if !enoughCandidates(g, m.Persons) && len(g.Members) == 0 {
+			r.Groups = append(r.Groups, g)
+		}
+	}
+	if len(r.Groups) == 0 {
+		return nil
+	}
+	for _, p := range m.Persons {
+		left := false
+		for _, pref := range p.Preferences {
+			if pref.IndexIn(r.Groups) == -1 {
+				left = true
+				break
+			}
+		}
+		if !left {
+			r.Persons = append(r.Persons, p)
+		}
+	}
+	return r
+}
+
+// returns the groups without the groups of the report and removes them from the wishes of the persons
+// with slots, a wish points to a remaining group with the same name instead if there is one
+func (r *Report) Apply(groups []*Group, persons []*Person) []*Group {
+	remaining := make([]*Group, 0, len(groups))
+	for _, g := range groups {
+		if g.IndexIn(r.Groups) == -1 {
+			remaining = append(remaining, g)
+		}
+	}
+	for _, p := range persons {
+		prefs := make([]*Group, 0, len(p.Preferences))
+		for _, pref := range p.Preferences {
+			if pref.IndexIn(r.Groups) == -1 {
+				prefs = append(prefs, pref)
+			} else if g := FindGroup(pref.Name, remaining); g != nil && g.IndexIn(prefs) == -1 {
+				prefs = append(prefs, g)
+			}
+		}
+		p.Preferences = prefs
+	}
+	return remaining
+}
+
+// returns the names of the groups of the report joined by sep
+func (r *Report) GroupNames(sep string) string {
+	names := make([]string, len(r.Groups))
+	for i, g := range r.Groups {
+		names[i] = g.Name
+	}
+	return strings.Join(names, sep)
+}
+
+// returns the names of the persons of the report joined by sep
+func (r *Report) PersonNames(sep string) string {
+	names := make([]string, len(r.Persons))
+	for i, p := range r.Persons {
+		names[i] = p.Name
+	}
+	return strings.Join(names, sep)
+}
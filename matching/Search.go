@@ -0,0 +1,358 @@
+package matching
+
+import (
+	"context"
+	"math/rand"
+)
+
+// a local search over the groups of the movable persons, all other members of the groups stay where they are
+// every state is rated by its violations (of group sizes and rules) and its costs (of ranks and split friendships)
+type search struct {
+	rc      RankCosts
+	groups  []*Group
+	movable []*Person
+	groupOf map[*Person]*Group
+	size    map[*Group]int
+	allowed map[*Person][]*Group
+	rules   []rule
+	rulesOf map[*Person][]rule
+	// friendships are counted even if both persons are fixed, which only adds a constant
+	friendships []friendship
+	friendsOf   map[*Person][]friendship
+}
+
+// a change of the groups of some persons
+type change []move
+
+type move struct {
+	p *Person
+	g *Group
+}
+
+// starts a search at the given assignment of the groupless persons of the matcher
+// persons that are missing in the assignment are put into their first preference
+func (m *Matcher) newSearch(a Assignment, rc RankCosts) *search {
+	s := &search{rc: rc, groups: m.Groups, groupOf: make(map[*Person]*Group), size: make(map[*Group]int),
+		allowed: make(map[*Person][]*Group), rules: m.rules(), rulesOf: make(map[*Person][]rule), friendsOf: make(map[*Person][]friendship)}
+	for _, g := range m.Groups {
+		for _, p := range g.Members {
+			s.groupOf[p] = g
+		}
+		s.size[g] = len(g.Members)
+	}
+	for _, p := range GetGrouplessPersons(m.Persons, m.Groups) {
+		for _, pref := range p.Preferences {
+			if pref.IndexIn(m.Groups) != -1 {
+				s.allowed[p] = append(s.allowed[p], pref)
+			}
+		}
+		if len(s.allowed[p]) == 0 {
+			continue
+		}
+		s.movable = append(s.movable, p)
+		g := a[p]
+		if g == nil {
+			g = s.allowed[p][0]
+		}
+		s.groupOf[p] = g
+		s.size[g]++
+	}
+	for _, r := range s.rules {
+		for _, p := range r.affected() {
+			s.rulesOf[p] = append(s.rulesOf[p], r)
+		}
+	}
+	s.friendships = m.friendships()
+	for _, f := range s.friendships {
+		s.friendsOf[f.p] = append(s.friendsOf[f.p], f)
+		s.friendsOf[f.friend] = append(s.friendsOf[f.friend], f)
+	}
+	return s
+}
+
+func (s *search) group(p *Person) *Group {
+	return s.groupOf[p]
+}
+
+func (s *search) sizeViolation(g *Group) int {
+	if s.size[g] < g.MinSize {
+		return g.MinSize - s.size[g]
+	}
+	if s.size[g] > g.Capacity {
+		return s.size[g] - g.Capacity
+	}
+	return 0
+}
+
+// returns the violations and costs of the current state
+func (s *search) rate() (violations, cost int) {
+	for _, g := range s.groups {
+		violations += s.sizeViolation(g)
+	}
+	for _, r := range s.rules {
+		violations += r.violations(s.group)
+	}
+	for _, p := range s.movable {
+		cost += s.rc.Cost(p, s.groupOf[p])
+	}
+	cost += s.rc.FriendCost() * splitFriendships(s.friendships, s.group)
+	return
+}
+
+// returns the assignment of the movable persons
+func (s *search) assignment() Assignment {
+	a := make(Assignment)
+	for _, p := range s.movable {
+		a[p] = s.groupOf[p]
+	}
+	return a
+}
+
+// applies the change and returns the change that undoes it
+func (s *search) apply(c change) change {
+	undo := make(change, len(c))
+	for i, mv := range c {
+		undo[len(c)-1-i] = move{mv.p, s.groupOf[mv.p]}
+		s.size[s.groupOf[mv.p]]--
+		s.groupOf[mv.p] = mv.g
+		s.size[mv.g]++
+	}
+	return undo
+}
+
+// returns how the violations and costs would change by applying the change
+func (s *search) delta(c change) (violations, cost int) {
+	// only the groups, rules and friendships of the moved persons can change
+	var groups []*Group
+	var rules []rule
+	var friendships []friendship
+	seen := make(map[interface{}]bool)
+	for _, mv := range c {
+		for _, g := range []*Group{s.groupOf[mv.p], mv.g} {
+			if !seen[g] {
+				seen[g] = true
+				groups = append(groups, g)
+			}
+		}
+		for _, r := range s.rulesOf[mv.p] {
+			if !seen[r] {
+				seen[r] = true
+				rules = append(rules, r)
+			}
+		}
+		for _, f := range s.friendsOf[mv.p] {
+			if !seen[f] {
+				seen[f] = true
+				friendships = append(friendships, f)
+			}
+		}
+	}
+	rate := func(sign int) {
+		for _, g := range groups {
+			violations += sign * s.sizeViolation(g)
+		}
+		for _, r := range rules {
+			violations += sign * r.violations(s.group)
+		}
+		for _, mv := range c {
+			cost += sign * s.rc.Cost(mv.p, s.groupOf[mv.p])
+		}
+		for _, f := range friendships {
+			if f.split(s.group) {
+				cost += sign * s.rc.FriendCost()
+			}
+		}
+	}
+	rate(-1)
+	undo := s.apply(c)
+	rate(1)
+	s.apply(undo)
+	return
+}
+
+// returns all changes that move the person, swap it with another person or move all persons that have to be
+// together with it
+func (s *search) changes(p *Person) []change {
+	var changes []change
+	from := s.groupOf[p]
+	for _, g := range s.allowed[p] {
+		if g == from {
+			continue
+		}
+		changes = append(changes, change{{p, g}})
+		for _, q := range s.movable {
+			if s.groupOf[q] == g && from.IndexIn(s.allowed[q]) != -1 {
+				changes = append(changes, change{{p, g}, {q, from}})
+			}
+		}
+	}
+	for _, r := range s.rulesOf[p] {
+		c, ok := r.(*Constraint)
+		if !ok || c.Kind != Together {
+			continue
+		}
+		for _, g := range s.allowed[p] {
+			var group change
+			for _, q := range c.Persons {
+				if s.allowed[q] != nil && s.groupOf[q] != g {
+					if g.IndexIn(s.allowed[q]) == -1 {
+						group = nil
+						break
+					}
+					group = append(group, move{q, g})
+				}
+			}
+			if len(group) > 1 {
+				changes = append(changes, group)
+			}
+		}
+	}
+	return changes
+}
+
+// returns the movable persons that are part of a violation
+func (s *search) conflicted() []*Person {
+	var conflicted []*Person
+	added := make(map[*Person]bool)
+	add := func(p *Person) {
+		if !added[p] && s.allowed[p] != nil {
+			added[p] = true
+			conflicted = append(conflicted, p)
+		}
+	}
+	for _, r := range s.rules {
+		if r.violations(s.group) > 0 {
+			for _, p := range r.affected() {
+				add(p)
+			}
+		}
+	}
+	for _, p := range s.movable {
+		g := s.groupOf[p]
+		if s.size[g] > g.Capacity {
+			add(p)
+		}
+		for _, pref := range s.allowed[p] {
+			if pref != g && s.size[pref] < pref.MinSize {
+				add(p)
+			}
+		}
+	}
+	return conflicted
+}
+
+// lowers the costs by the best change of the given persons that doesn't add violations until no change improves them
+// anymore, maxSteps changes were made or ctx is done. With chains, ejection chains are tried if no other change
+// improves the costs.
+func (s *search) improve(ctx context.Context, maxSteps int, persons []*Person, chains bool) {
+	for step := 0; step < maxSteps && ctx.Err() == nil; step++ {
+		chosen := s.best(ctx, persons, s.changes)
+		if chosen == nil && chains {
+			chosen = s.best(ctx, persons, s.chains)
+		}
+		if chosen == nil {
+			return
+		}
+		s.apply(chosen)
+	}
+}
+
+// returns the change of the given persons that lowers the costs most without adding violations, nil if there is none
+func (s *search) best(ctx context.Context, persons []*Person, changes func(p *Person) []change) change {
+	var chosen change
+	dc := 0
+	for _, p := range persons {
+		if ctx.Err() != nil {
+			return nil
+		}
+		for _, c := range changes(p) {
+			if v, k := s.delta(c); v <= 0 && k < dc {
+				chosen, dc = c, k
+			}
+		}
+	}
+	return chosen
+}
+
+// returns the ejection chains that move the person into another group and a movable member of that group into a
+// third group, which has spare places or whose member moves into the group of the person in turn
+func (s *search) chains(p *Person) []change {
+	var changes []change
+	members := make(map[*Group][]*Person)
+	for _, q := range s.movable {
+		members[s.groupOf[q]] = append(members[s.groupOf[q]], q)
+	}
+	from := s.groupOf[p]
+	for _, g := range s.allowed[p] {
+		if g == from {
+			continue
+		}
+		for _, q := range members[g] {
+			for _, h := range s.allowed[q] {
+				// moving q into the group of p is a swap
+				if h == g || h == from {
+					continue
+				}
+				if s.size[h] < h.Capacity {
+					changes = append(changes, change{{p, g}, {q, h}})
+				}
+				for _, r := range members[h] {
+					if from.IndexIn(s.allowed[r]) != -1 {
+						changes = append(changes, change{{p, g}, {q, h}, {r, from}})
+					}
+				}
+			}
+		}
+	}
+	return changes
+}
+
+// probability of choosing a random change instead of the best one to leave local minima
+const noise = 0.1
+
+// tries to remove all violations while keeping the costs low, the best state found within maxSteps or until ctx is
+// done is kept, returns whether it has no violations
+func (s *search) repair(ctx context.Context, maxSteps int, rnd *rand.Rand) bool {
+	violations, cost := s.rate()
+	bestViolations, bestCost, best := violations, cost, s.assignment()
+	for step := 0; step < maxSteps && bestViolations > 0 && ctx.Err() == nil; step++ {
+		conflicted := s.conflicted()
+		if len(conflicted) == 0 {
+			break
+		}
+		changes := s.changes(conflicted[rnd.Intn(len(conflicted))])
+		if len(changes) == 0 {
+			continue
+		}
+
+		// take the best change, ties are broken randomly
+		var chosen change
+		dv, dc, ties := 0, 0, 0
+		if rnd.Float64() < noise {
+			chosen = changes[rnd.Intn(len(changes))]
+			dv, dc = s.delta(chosen)
+		} else {
+			for _, c := range changes {
+				v, k := s.delta(c)
+				if chosen == nil || v < dv || v == dv && k < dc {
+					chosen, dv, dc, ties = c, v, k, 1
+				} else if v == dv && k == dc {
+					ties++
+					if rnd.Intn(ties) == 0 {
+						chosen = c
+					}
+				}
+			}
+		}
+		s.apply(chosen)
+		violations, cost = violations+dv, cost+dc
+		if violations < bestViolations || violations == bestViolations && cost < bestCost {
+			bestViolations, bestCost, best = violations, cost, s.assignment()
+		}
+	}
+
+	for p, g := range best {
+		s.apply(change{{p, g}})
+	}
+	return bestViolations == 0
+}
@@ -0,0 +1,277 @@
+package matching
+
+import "errors"
+
+// Groups can belong to slots like the rounds of a project day. Every person gets exactly one group in every slot and
+// never two groups with the same name, so a workshop that is offered in several slots is attended only once. The
+// wishes of a person are for workshops and count in every slot that offers them.
+
+// returns the slots of the groups in the order of their first group, nil if no group belongs to a slot
+func Slots(groups []*Group) []string {
+	var slots []string
+	seen := make(map[string]bool)
+	named := false
+	for _, g := range groups {
+		if g.Slot != "" {
+			named = true
+		}
+		if !seen[g.Slot] {
+			seen[g.Slot] = true
+			slots = append(slots, g.Slot)
+		}
+	}
+	if !named {
+		return nil
+	}
+	return slots
+}
+
+// returns the groups that belong to the slot
+func GroupsInSlot(groups []*Group, slot string) []*Group {
+	ret := make([]*Group, 0)
+	for _, g := range groups {
+		if g.Slot == slot {
+			ret = append(ret, g)
+		}
+	}
+	return ret
+}
+
+// returns the group with the given name in the slot
+func FindGroupInSlot(name, slot string, groups []*Group) *Group {
+	for _, g := range groups {
+		if g.Name == name && g.Slot == slot {
+			return g
+		}
+	}
+	return nil
+}
+
+// returns the group of the person in the slot, nil if it has none
+func (p *Person) GroupInSlot(groups []*Group, slot string) *Group {
+	for _, g := range groups {
+		if g.Slot == slot && p.IndexIn(g.Members) != -1 {
+			return g
+		}
+	}
+	return nil
+}
+
+// returns the persons that don't have a group in the slot
+func GetGrouplessPersonsInSlot(persons []*Person, groups []*Group, slot string) []*Person {
+	ret := make([]*Person, 0)
+	for _, p := range persons {
+		if p.GroupInSlot(groups, slot) == nil {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// returns the persons that miss a group in any slot, without slots these are the groupless persons
+func GetIncompletePersons(persons []*Person, groups []*Group) []*Person {
+	slots := Slots(groups)
+	if slots == nil {
+		return GetGrouplessPersons(persons, groups)
+	}
+	ret := make([]*Person, 0)
+	for _, p := range persons {
+		for _, slot := range slots {
+			if p.GroupInSlot(groups, slot) == nil {
+				ret = append(ret, p)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// returns a function for every slot that returns the group of a person in the slot, a single one without slots
+func slotGroupOfs(groups []*Group) []func(*Person) *Group {
+	slots := Slots(groups)
+	if slots == nil {
+		return []func(*Person) *Group{func(p *Person) *Group {
+			return p.GetGroup(groups)
+		}}
+	}
+	groupOfs := make([]func(*Person) *Group, len(slots))
+	for i, slot := range slots {
+		slot := slot
+		groupOfs[i] = func(p *Person) *Group {
+			return p.GroupInSlot(groups, slot)
+		}
+	}
+	return groupOfs
+}
+
+// a matcher with slots converted into one without: every person is replaced by a copy for every slot that wishes for
+// the groups of that slot, and copies of the same person must not get groups with the same name
+type slotMatcher struct {
+	*Matcher
+	// the originals of the copied persons and groups
+	person map[*Person]*Person
+	group  map[*Group]*Group
+	// the slot of every copied person
+	slot map[*Person]string
+}
+
+// returns the matcher converted into one without slots, the conversion is done on every call so that it follows the
+// changes of the groups between two runs
+func (m *Matcher) slotted() *slotMatcher {
+	slots := Slots(m.Groups)
+	s := &slotMatcher{person: make(map[*Person]*Person), group: make(map[*Group]*Group), slot: make(map[*Person]string)}
+	groups := make([]*Group, len(m.Groups))
+	copyOf := make(map[*Group]*Group)
+	for i, g := range m.Groups {
+		groups[i] = NewGroup(g.Name, g.Capacity, g.MinSize)
+		groups[i].Slot = g.Slot
+		copyOf[g] = groups[i]
+		s.group[groups[i]] = g
+	}
+	s.Matcher = NewMatcher(make([]*Person, 0), groups)
+	s.RankCosts = m.RankCosts
+	s.Incremental = m.Incremental
+
+	// copy the persons that are matched and the members of the groups for every slot they need
+	matched := make(map[*Person]bool)
+	for _, p := range m.Persons {
+		matched[p] = true
+	}
+	copies := make(map[*Person][]*Person)
+	for _, p := range m.allPersons() {
+		copies[p] = make([]*Person, len(slots))
+		for k, slot := range slots {
+			g := p.GroupInSlot(m.Groups, slot)
+			if g == nil && !matched[p] {
+				continue
+			}
+			c := &Person{Name: p.Name, Attributes: p.Attributes, original: p}
+			for _, pref := range p.Preferences {
+				if h := FindGroupInSlot(pref.Name, slot, groups); h != nil {
+					c.Preferences = append(c.Preferences, h)
+				}
+			}
+			if g != nil {
+				copyOf[g].Members = append(copyOf[g].Members, c)
+				if g.IsLocked(p) {
+					copyOf[g].Locked = append(copyOf[g].Locked, c)
+				}
+			} else {
+				s.Persons = append(s.Persons, c)
+			}
+			copies[p][k] = c
+			s.person[c] = p
+			s.slot[c] = slot
+		}
+	}
+
+	// friends and constraints apply within every slot
+	for p, c := range copies {
+		for k := range slots {
+			if c[k] == nil {
+				continue
+			}
+			for _, f := range p.Friends {
+				if fc := copies[f]; fc != nil && fc[k] != nil {
+					c[k].Friends = append(c[k].Friends, fc[k])
+				}
+			}
+		}
+	}
+	for _, c := range m.Constraints {
+		for k := range slots {
+			var persons []*Person
+			for _, p := range c.Persons {
+				if pc := copies[p]; pc != nil && pc[k] != nil {
+					persons = append(persons, pc[k])
+				}
+			}
+			if len(persons) > 1 {
+				s.Constraints = append(s.Constraints, NewConstraint(c.Kind, persons))
+			}
+		}
+	}
+	for _, r := range m.Balance {
+		b := *r
+		if r.Group != nil {
+			b.Group = copyOf[r.Group]
+		}
+		s.Balance = append(s.Balance, &b)
+	}
+	for _, p := range m.allPersons() {
+		d := &distinct{}
+		for _, c := range copies[p] {
+			if c != nil {
+				d.copies = append(d.copies, c)
+			}
+		}
+		if len(d.copies) > 1 {
+			s.distinct = append(s.distinct, d)
+		}
+	}
+	return s
+}
+
+// inserts the originals of the persons of the assignment into the originals of their groups
+func (s *slotMatcher) apply(a Assignment) {
+	for _, c := range s.Persons {
+		if g, ok := a[c]; ok {
+			s.group[g].Members = append(s.group[g].Members, s.person[c])
+		}
+	}
+}
+
+// checks the matcher converted into one without slots
+func (m *Matcher) checkSlots() (error, string) {
+	if !m.Incremental && m.numberAssigned() != 0 {
+		return errors.New("assigned_persons"), ""
+	}
+	s := m.slotted()
+	for _, c := range s.Persons {
+		if len(c.Preferences) == 0 {
+			return errors.New("person_no_pref"), c.Name + " (" + s.slot[c] + ")"
+		}
+	}
+	// the copies only wish for the groups of their slot, so the diagnosis of the sizes is done for every slot
+	return s.check()
+}
+
+// the copies of a person for the different slots, no two of them may be in groups with the same name
+type distinct struct {
+	copies []*Person
+}
+
+func (d *distinct) affected() []*Person {
+	return d.copies
+}
+
+// the number of pairs of copies in groups with the same name
+func (d *distinct) violations(groupOf func(*Person) *Group) int {
+	n := 0
+	for i, p := range d.copies {
+		for _, q := range d.copies[i+1:] {
+			if g, h := groupOf(p), groupOf(q); g != nil && h != nil && g.Name == h.Name {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func (d *distinct) branch(groupOf func(*Person) *Group, fixed func(*Person) bool) (*Person, *Group) {
+	for i, p := range d.copies {
+		for _, q := range d.copies[i+1:] {
+			g, h := groupOf(p), groupOf(q)
+			if g == nil || h == nil || g.Name != h.Name {
+				continue
+			}
+			if !fixed(p) {
+				return p, g
+			}
+			if !fixed(q) {
+				return q, h
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,191 @@
+package matching
+
+import (
+	"context"
+	"testing"
+)
+
+// returns the groups of the slots with the given capacities and minimal sizes, the workshops are named A, B, ... in
+// every slot, and the persons a, b, ... with the given wishes for the workshops
+func newSlotProject(slots map[string][][2]int, wishes [][]int) ([]*Group, []*Person) {
+	var groups []*Group
+	for _, slot := range []string{"1", "2", "3"} {
+		for i, size := range slots[slot] {
+			g := NewGroup(string(rune('A'+i)), size[0], size[1])
+			g.Slot = slot
+			groups = append(groups, g)
+		}
+	}
+	var persons []*Person
+	for i, w := range wishes {
+		var preferences []*Group
+		for _, k := range w {
+			preferences = append(preferences, groups[k])
+		}
+		persons = append(persons, NewPerson(string(rune('a'+i)), preferences))
+	}
+	return groups, persons
+}
+
+// checks that every person has exactly one group in every slot, never the same workshop twice and that no group is
+// too small or too large
+func checkSlotAssignment(t *testing.T, name string, groups []*Group, persons []*Person) {
+	for _, g := range groups {
+		if len(g.Members) < g.MinSize || len(g.Members) > g.Capacity {
+			t.Errorf("%s: group %s (%s) has %d members", name, g.Name, g.Slot, len(g.Members))
+		}
+	}
+	for _, p := range persons {
+		workshops := make(map[string]bool)
+		for _, slot := range Slots(groups) {
+			n := 0
+			for _, g := range GroupsInSlot(groups, slot) {
+				if p.IndexIn(g.Members) == -1 {
+					continue
+				}
+				n++
+				if workshops[g.Name] {
+					t.Errorf("%s: %s attends %s twice", name, p.Name, g.Name)
+				}
+				workshops[g.Name] = true
+			}
+			if n != 1 {
+				t.Errorf("%s: %s has %d groups in slot %s", name, p.Name, n, slot)
+			}
+		}
+	}
+}
+
+func TestSlotsRespected(t *testing.T) {
+	for _, solver := range SolverNames() {
+		groups, persons := newSlotProject(map[string][][2]int{
+			"1": {{2, 0}, {2, 0}, {1, 0}},
+			"2": {{2, 0}, {2, 0}, {2, 0}},
+		}, [][]int{{0, 1, 2}, {0, 1}, {0, 2, 1}, {1, 0, 2}, {2, 0, 1}})
+		m := NewMatcher(persons, groups)
+		if err, names := m.CheckMatcher(); err != nil {
+			t.Fatalf("%s: %v: %s", solver, err, names)
+		}
+		if _, err := m.Solve(context.Background(), testOptions(solver)); err != nil {
+			t.Errorf("%s: %v", solver, err)
+			continue
+		}
+		checkSlotAssignment(t, solver, groups, persons)
+	}
+}
+
+// the slots offer the workshops with different capacities, so who gets A in the first slot gets B in the second
+func TestSlotsWithDifferentCapacities(t *testing.T) {
+	for _, solver := range SolverNames() {
+		groups, persons := newSlotProject(map[string][][2]int{
+			"1": {{3, 0}, {1, 0}},
+			"2": {{1, 0}, {3, 0}},
+		}, [][]int{{0, 1}, {0, 1}, {0, 1}, {0, 1}})
+		m := NewMatcher(persons, groups)
+		if _, err := m.Solve(context.Background(), testOptions(solver)); err != nil {
+			t.Errorf("%s: %v", solver, err)
+			continue
+		}
+		checkSlotAssignment(t, solver, groups, persons)
+		for i, want := range []int{3, 1, 1, 3} {
+			if len(groups[i].Members) != want {
+				t.Errorf("%s: group %s (%s) has %d members, want %d", solver, groups[i].Name, groups[i].Slot, len(groups[i].Members), want)
+			}
+		}
+	}
+}
+
+// the conversion of the slots follows changes of the groups between two runs of the same matcher
+func TestSlotsChangedBetweenSolves(t *testing.T) {
+	for _, solver := range SolverNames() {
+		groups, persons := newSlotProject(map[string][][2]int{
+			"1": {{3, 0}, {1, 0}},
+			"2": {{1, 0}, {3, 0}},
+		}, [][]int{{0, 1}, {0, 1}, {0, 1}, {0, 1}})
+		m := NewMatcher(persons, groups)
+		if _, err := m.Solve(context.Background(), testOptions(solver)); err != nil {
+			t.Errorf("%s: %v", solver, err)
+			continue
+		}
+		checkSlotAssignment(t, solver, groups, persons)
+
+		ResetGroups(groups)
+		for i, capacity := range []int{1, 3, 3, 1} {
+			groups[i].Capacity = capacity
+		}
+		if _, err := m.Solve(context.Background(), testOptions(solver)); err != nil {
+			t.Errorf("%s, second run: %v", solver, err)
+			continue
+		}
+		checkSlotAssignment(t, solver+", second run", groups, persons)
+	}
+}
+
+func TestCheckSlots(t *testing.T) {
+	tests := []struct {
+		name   string
+		slots  map[string][][2]int
+		wishes [][]int
+		want   string
+		names  string
+		// the slot of the groups of the conflict that Diagnose finds for the copies of the persons
+		slot string
+	}{
+		{
+			name:   "feasible",
+			slots:  map[string][][2]int{"1": {{2, 0}, {2, 0}}, "2": {{2, 0}, {2, 0}}},
+			wishes: [][]int{{0, 1}, {1, 0}, {0, 1}},
+		},
+		{
+			name:   "too few places in a slot",
+			slots:  map[string][][2]int{"1": {{2, 0}, {2, 0}}, "2": {{1, 0}, {1, 0}}},
+			wishes: [][]int{{0, 1}, {1, 0}, {0, 1}},
+			want:   "combination_overfilled",
+			names:  "A|B (1): a, b, c",
+			slot:   "2",
+		},
+		{
+			name:   "too few persons for the minimal sizes of a slot",
+			slots:  map[string][][2]int{"1": {{2, 0}, {2, 0}}, "2": {{2, 2}, {2, 2}}},
+			wishes: [][]int{{0, 1}, {1, 0}, {0, 1}},
+			want:   "combination_underfilled",
+			names:  "A|B (1): a, b, c",
+			slot:   "2",
+		},
+		{
+			name:   "workshop not offered in a slot",
+			slots:  map[string][][2]int{"1": {{2, 0}, {2, 0}}, "2": {{2, 0}}},
+			wishes: [][]int{{0, 1}, {1}},
+			want:   "person_no_pref",
+			names:  "b (2)",
+		},
+	}
+
+	for _, test := range tests {
+		groups, persons := newSlotProject(test.slots, test.wishes)
+		m := NewMatcher(persons, groups)
+		err, names := m.CheckMatcher()
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v: %s", test.name, err, names)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.want || names != test.names {
+			t.Errorf("%s: CheckMatcher returns %v: %s, want %s: %s", test.name, err, names, test.want, test.names)
+		}
+		if test.slot == "" {
+			continue
+		}
+		c := m.slotted().Diagnose()
+		if c == nil {
+			t.Errorf("%s: no conflict", test.name)
+			continue
+		}
+		for _, g := range c.Groups {
+			if g.Slot != test.slot {
+				t.Errorf("%s: the conflict %s contains group %s of slot %s", test.name, c, g.Name, g.Slot)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package matching
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// A Solver is a strategy to assign the groupless persons of a matcher to their groups.
+// Solvers must not change the matcher, the found assignment is applied by the caller.
+type Solver interface {
+	Name() string
+	Solve(ctx context.Context, m *Matcher, opts Options) (Assignment, Stats, error)
+}
+
+// Assignment maps persons to the group they should be inserted into
+type Assignment map[*Person]*Group
+
+// sum of the rank costs of all assigned persons
+func (a Assignment) cost(rc RankCosts) (n int) {
+	for p, g := range a {
+		n += rc.Cost(p, g)
+	}
+	return
+}
+
+// quote of the assigned persons (see CalcQuote)
+func (a Assignment) quote(rc RankCosts) float64 {
+	if len(a) == 0 {
+		return 0
+	}
+	return 1 + float64(a.cost(rc))/float64(len(a))
+}
+
+// Progress is reported regularly by long running solvers
+type Progress struct {
+	Attempts  int
+	Found     int
+	BestQuote float64
+	Elapsed   time.Duration
+}
+
+// time between two progress reports
+const progressInterval = 250 * time.Millisecond
+
+// Stats describe a single run of a solver
+type Stats struct {
+	Solver   string
+	Attempts int
+	Duration time.Duration
+	// the seed of the random numbers and the length of the cooling schedule of the annealing solver (0 for other
+	// solvers), which reproduce the run (see Options.Record)
+	Seed        int64
+	AnnealSteps int
+}
+
+// returns a source of random numbers for the seed of a run, every solver takes all random numbers from it
+func newRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// returns a new seed for a run without a given one, it's never 0
+func newSeed() int64 {
+	seed := time.Now().UnixNano() & math.MaxInt32
+	if seed == 0 {
+		seed = 1
+	}
+	return seed
+}
+
+// all solvers that can be selected by name
+var solvers = make(map[string]Solver)
+
+// makes a solver available under its name, later registrations replace former ones
+func RegisterSolver(s Solver) {
+	solvers[s.Name()] = s
+}
+
+func GetSolver(name string) Solver {
+	return solvers[name]
+}
+
+// returns the names of all registered solvers in alphabetical order
+func SolverNames() []string {
+	names := make([]string, 0, len(solvers))
+	for name := range solvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inserts all persons of the assignment into their groups
+func (m *Matcher) Apply(a Assignment) {
+	// iterate over the persons of the matcher to keep the order of insertion stable
+	for _, p := range m.Persons {
+		if g, ok := a[p]; ok {
+			g.Members = append(g.Members, p)
+		}
+	}
+}
+
+// solves the matcher with the solver selected in opts and applies the result
+// an assignment is also applied if the solver returns an error together with it (e.g. softtimeout)
+// with slots, the solver gets a copy of every person for every slot (see slotMatcher)
+// without opts.Seed, a new seed is chosen, which is returned in the stats
+func (m *Matcher) Solve(ctx context.Context, opts Options) (Stats, error) {
+	s := GetSolver(opts.Solver)
+	if s == nil {
+		return Stats{}, errors.New("solver_not_found")
+	}
+	if opts.Seed == 0 {
+		opts.Seed = newSeed()
+	}
+	start := time.Now()
+	target := m
+	var slotted *slotMatcher
+	if Slots(m.Groups) != nil {
+		slotted = m.slotted()
+		target = slotted.Matcher
+	}
+	// the solvers only know rank costs, which express the objective
+	rc, step := opts.objectiveCosts(GetGrouplessPersons(target.Persons, target.Groups))
+	opts.RankCosts = rc
+	opts.StartTemperature *= float64(step)
+
+	a, stats, err := s.Solve(ctx, target, opts)
+	if a != nil && opts.Improve {
+		a = target.improved(ctx, a, opts.RankCosts)
+	}
+	if a != nil && slotted != nil {
+		slotted.apply(a)
+	} else if a != nil {
+		m.Apply(a)
+	}
+	stats.Solver = s.Name()
+	stats.Duration = time.Since(start)
+	stats.Seed = opts.Seed
+	return stats, err
+}
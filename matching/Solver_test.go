@@ -0,0 +1,180 @@
+package matching
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// solver that assigns nobody, to test the registry
+type testSolver struct {
+	name string
+	err  error
+}
+
+func (s testSolver) Name() string {
+	return s.name
+}
+
+func (s testSolver) Solve(ctx context.Context, m *Matcher, opts Options) (Assignment, Stats, error) {
+	return Assignment{}, Stats{Attempts: 1}, s.err
+}
+
+func TestSolverRegistry(t *testing.T) {
+	want := []string{"annealing", "exact", "heuristic"}
+	if names := SolverNames(); !reflect.DeepEqual(names, want) {
+		t.Errorf("solvers are %v, want %v", names, want)
+	}
+	for _, name := range want {
+		if s := GetSolver(name); s == nil || s.Name() != name {
+			t.Errorf("solver %s isn't found by its name", name)
+		}
+	}
+	if GetSolver("unknown") != nil {
+		t.Error("an unknown solver is found")
+	}
+
+	defer delete(solvers, "test")
+	RegisterSolver(testSolver{name: "test"})
+	RegisterSolver(testSolver{name: "test", err: errors.New("replaced")})
+	if names := SolverNames(); !reflect.DeepEqual(names, []string{"annealing", "exact", "heuristic", "test"}) {
+		t.Errorf("solvers are %v after registering one", names)
+	}
+	opts := DefaultOptions()
+	opts.Solver = "test"
+	stats, err := NewMatcher(nil, nil).Solve(context.Background(), opts)
+	if err == nil || err.Error() != "replaced" {
+		t.Errorf("the registered solver isn't replaced: %v", err)
+	}
+	if stats.Solver != "test" || stats.Attempts != 1 || stats.Seed == 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	opts.Solver = "unknown"
+	if _, err := NewMatcher(nil, nil).Solve(context.Background(), opts); err == nil || err.Error() != "solver_not_found" {
+		t.Errorf("solving with an unknown solver returns %v", err)
+	}
+	if err := opts.Set("solver", "unknown"); err == nil || err.Error() != "solver_not_found" {
+		t.Errorf("setting an unknown solver returns %v", err)
+	}
+}
+
+// a project in which every person can get its first wish only if the minimal sizes are ignored
+func solverProject() *Matcher {
+	a, b, c := NewGroup("A", 3, 1), NewGroup("B", 3, 2), NewGroup("C", 3, 0)
+	persons := []*Person{
+		NewPerson("a", []*Group{a, b}),
+		NewPerson("b", []*Group{a, b}),
+		NewPerson("c", []*Group{a, b, c}),
+		NewPerson("d", []*Group{c, b}),
+		NewPerson("e", []*Group{c, a}),
+	}
+	return NewMatcher(persons, []*Group{a, b, c})
+}
+
+func TestSolvers(t *testing.T) {
+	for _, name := range SolverNames() {
+		m := solverProject()
+		opts := DefaultOptions()
+		opts.Solver, opts.Seed, opts.AnnealSteps = name, 1, 10000
+		opts.SoftTimeout, opts.HardTimeout = time.Second, 5*time.Second
+		stats, err := m.Solve(context.Background(), opts)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if stats.Solver != name || stats.Seed != 1 {
+			t.Errorf("%s: unexpected stats %+v", name, stats)
+		}
+		if groupless := GetGrouplessPersons(m.Persons, m.Groups); len(groupless) > 0 {
+			t.Errorf("%s: %v are groupless", name, groupless)
+		}
+		for _, g := range m.Groups {
+			if len(g.Members) < g.MinSize || len(g.Members) > g.Capacity {
+				t.Errorf("%s: group %s has %d members", name, g.Name, len(g.Members))
+			}
+		}
+		// the optimum puts two of the persons that wished for A first into B
+		if quote, _ := m.CalcQuote(); quote != 1.4 {
+			t.Errorf("%s: quote is %v, want 1.4", name, quote)
+		}
+	}
+}
+
+func TestSolverCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, name := range SolverNames() {
+		m := solverProject()
+		opts := DefaultOptions()
+		opts.Solver = name
+		if _, err := m.Solve(ctx, opts); err == nil || err.Error() != "canceled" {
+			t.Errorf("%s: a canceled run returns %v", name, err)
+		}
+		if !AllEmpty(m.Groups) {
+			t.Errorf("%s: a canceled run assigned persons", name)
+		}
+	}
+}
+
+func TestSolverProgress(t *testing.T) {
+	for _, name := range SolverNames() {
+		var last Progress
+		reports := 0
+		opts := DefaultOptions()
+		opts.Solver, opts.Seed, opts.AnnealSteps = name, 1, 10000
+		opts.Progress = func(p Progress) {
+			reports++
+			last = p
+		}
+		if _, err := solverProject().Solve(context.Background(), opts); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		// the progress is reported at least once when the solver is finished
+		if reports == 0 || last.Attempts == 0 || last.Found == 0 || last.BestQuote < 1 {
+			t.Errorf("%s: %d reports, the last one is %+v", name, reports, last)
+		}
+	}
+}
+
+// returns the group of every person after solving a random project with the given options
+func solveRandomProject(opts Options) []string {
+	rnd := rand.New(rand.NewSource(7))
+	var groups []*Group
+	for i := 0; i < 5; i++ {
+		groups = append(groups, NewGroup(string(rune('A'+i)), 8, 2))
+	}
+	var persons []*Person
+	for i := 0; i < 30; i++ {
+		var preferences []*Group
+		for _, k := range rnd.Perm(len(groups))[:3] {
+			preferences = append(preferences, groups[k])
+		}
+		persons = append(persons, NewPerson("p"+strconv.Itoa(i), preferences))
+	}
+	NewMatcher(persons, groups).Solve(context.Background(), opts)
+	assigned := make([]string, len(persons))
+	for i, p := range persons {
+		if g := p.GetGroup(groups); g != nil {
+			assigned[i] = g.Name
+		}
+	}
+	return assigned
+}
+
+// solving twice with the same seed gives the same assignment
+func TestSolverSeed(t *testing.T) {
+	for _, name := range []string{"heuristic", "annealing"} {
+		opts := testOptions(name)
+		opts.Seed = 42
+		first, second := solveRandomProject(opts), solveRandomProject(opts)
+		if !reflect.DeepEqual(first, second) {
+			t.Errorf("%s: the assignments %v and %v differ", name, first, second)
+		}
+	}
+}
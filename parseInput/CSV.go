@@ -0,0 +1,349 @@
+// Import and export to/from comma separated values
+package parseInput
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/veecue/GroupMatcher/matching"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+//Describes which columns of a .csv file contain which information (columns start at 0)
+type CSVMapping struct {
+	//column of the person name
+	Name int
+	//columns of the preferences in rank order, nil for all columns that are not used otherwise
+	Preferences []int
+	//column containing a group the person is already assigned to, -1 for none
+	Assigned int
+	//columns containing attributes of the person, their heading (or the column number without headings) is the key
+	Attributes []int
+	//whether the first line contains headings
+	Header bool
+	//separator of the columns, 0 to detect it from the first line
+	Comma rune
+	//sizes of groups that are not given in a groups file, a capacity of 0 allows all persons in a group
+	MinSize, Capacity int
+	//if the assigned column is -1, the column with this heading is used instead
+	AssignedHeading string
+	//columns with these headings are ignored when using all columns as preferences
+	IgnoreHeadings []string
+
+	//columns ignored because of their heading
+	ignored []int
+	//keys of the attribute columns
+	keys []string
+}
+
+//Returns a mapping for files with the name in the first column and preferences in all other columns
+func DefaultCSVMapping() CSVMapping {
+	return CSVMapping{Name: 0, Assigned: -1, Header: true}
+}
+
+//Converts .csv files into a project. The persons file contains names and preferences as given by the mapping,
+//the optional groups file contains name, minimal and maximal size of every group. Without a groups file all
+//groups named in the preferences are created with the sizes of the mapping.
+//Errors are a ParseError with the line of the persons file, any error in the groups file is "csv_groups_error" with its line.
+func ParseCSV(persons io.Reader, groups io.Reader, mapping CSVMapping) (*matching.Project, error) {
+	project := matching.NewProject(make([]*matching.Group, 0), make([]*matching.Person, 0))
+
+	//read groups
+	if groups != nil {
+		records, err := readCSV(groups, mapping.Comma)
+		if err != nil {
+			return nil, errors.New("csv_groups_error")
+		}
+		for i, record := range records {
+			if len(record) < 3 {
+				return nil, lineError("csv_groups_error", i+1)
+			}
+			name := strings.TrimSpace(record[0])
+			min, errMin := strconv.Atoi(strings.TrimSpace(record[1]))
+			cap, errCap := strconv.Atoi(strings.TrimSpace(record[2]))
+			if errMin != nil || errCap != nil || min < 0 || min > cap {
+				//ignore a heading line
+				if i == 0 && mapping.Header {
+					continue
+				}
+				return nil, lineError("csv_groups_error", i+1)
+			}
+			if name == "" || matching.FindGroup(name, project.Groups) != nil {
+				return nil, lineError("csv_groups_error", i+1)
+			}
+			project.Groups = append(project.Groups, matching.NewGroup(name, cap, min))
+		}
+	}
+
+	//read persons
+	records, err := readCSV(persons, mapping.Comma)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || (mapping.Header && len(records) == 1) {
+		return nil, errors.New("empty_file")
+	}
+	start := 0
+	if mapping.Header {
+		start = 1
+		mapping = mapping.withHeadings(records[0])
+	}
+	for i, c := range mapping.Attributes {
+		if len(mapping.keys) <= i {
+			mapping.keys = append(mapping.keys, strconv.Itoa(c+1))
+		}
+	}
+	for i := start; i < len(records); i++ {
+		record := records[i]
+		prefColumns := mapping.Preferences
+		if prefColumns == nil {
+			prefColumns = mapping.remainingColumns(len(record))
+		}
+		//skip empty lines that are common at the end of exported forms
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		if mapping.Name >= len(record) || mapping.Assigned >= len(record) {
+			return nil, lineError("csv_column_missing", i+1)
+		}
+		name := strings.TrimSpace(record[mapping.Name])
+		if name == "" {
+			return nil, lineError("empty_argument", i+1)
+		}
+		if matching.FindPerson(name, project.Persons) != nil {
+			return nil, lineError("person_name_not_unique", i+1)
+		}
+
+		//empty preferences are skipped, as forms often contain optional choices
+		var prefs []*matching.Group
+		for _, c := range prefColumns {
+			if c >= len(record) || strings.TrimSpace(record[c]) == "" {
+				continue
+			}
+			g, err := findOrCreateGroup(project, strings.TrimSpace(record[c]), groups == nil, mapping)
+			if err != nil {
+				return nil, lineError(err.Error(), i+1)
+			}
+			if g.IndexIn(prefs) == -1 {
+				prefs = append(prefs, g)
+			}
+		}
+		if len(prefs) == 0 {
+			return nil, lineError("missing_argument", i+1)
+		}
+		p := matching.NewPerson(name, prefs)
+		project.Persons = append(project.Persons, p)
+
+		//empty attribute cells are skipped as well
+		for j, c := range mapping.Attributes {
+			if c < len(record) && strings.TrimSpace(record[c]) != "" {
+				if p.Attributes == nil {
+					p.Attributes = make(map[string]string)
+				}
+				p.Attributes[mapping.keys[j]] = strings.TrimSpace(record[c])
+			}
+		}
+
+		if mapping.Assigned >= 0 && strings.TrimSpace(record[mapping.Assigned]) != "" {
+			g, err := findOrCreateGroup(project, strings.TrimSpace(record[mapping.Assigned]), groups == nil, mapping)
+			if err != nil {
+				return nil, lineError(err.Error(), i+1)
+			}
+			g.Members = append(g.Members, p)
+		}
+	}
+
+	if groups == nil && mapping.Capacity == 0 {
+		for _, g := range project.Groups {
+			g.Capacity = len(project.Persons)
+		}
+	}
+	return project, nil
+}
+
+//uses the headings to find the assigned and ignored columns and the keys of the attributes
+func (mapping CSVMapping) withHeadings(headings []string) CSVMapping {
+	mapping.keys = nil
+	for _, c := range mapping.Attributes {
+		key := strconv.Itoa(c + 1)
+		if c < len(headings) && strings.TrimSpace(headings[c]) != "" {
+			key = strings.TrimSpace(headings[c])
+		}
+		mapping.keys = append(mapping.keys, key)
+	}
+	for i, heading := range headings {
+		heading = strings.TrimSpace(heading)
+		if mapping.Assigned == -1 && mapping.AssignedHeading != "" && heading == mapping.AssignedHeading {
+			mapping.Assigned = i
+		}
+		for _, ignored := range mapping.IgnoreHeadings {
+			if mapping.Preferences == nil && heading == ignored {
+				mapping.ignored = append(mapping.ignored, i)
+			}
+		}
+	}
+	return mapping
+}
+
+//returns all columns that are not used for anything else
+func (mapping CSVMapping) remainingColumns(n int) []int {
+	var columns []int
+	for i := 0; i < n; i++ {
+		if i == mapping.Name || i == mapping.Assigned {
+			continue
+		}
+		ignored := false
+		for _, j := range mapping.ignored {
+			if i == j {
+				ignored = true
+			}
+		}
+		for _, j := range mapping.Attributes {
+			if i == j {
+				ignored = true
+			}
+		}
+		if !ignored {
+			columns = append(columns, i)
+		}
+	}
+	return columns
+}
+
+//returns the group with the given name, creating it if allowed
+func findOrCreateGroup(project *matching.Project, name string, create bool, mapping CSVMapping) (*matching.Group, error) {
+	g := matching.FindGroup(name, project.Groups)
+	if g == nil {
+		if !create {
+			return nil, errors.New("group_not_found")
+		}
+		g = matching.NewGroup(name, mapping.Capacity, mapping.MinSize)
+		project.Groups = append(project.Groups, g)
+	}
+	return g, nil
+}
+
+//reads all records of a .csv file while detecting its encoding and separator
+func readCSV(data io.Reader, comma rune) ([][]string, error) {
+	raw, err := ioutil.ReadAll(data)
+	if err != nil {
+		return nil, err
+	}
+	raw, err = decodeText(raw)
+	if err != nil {
+		return nil, errors.New("syntax_error")
+	}
+	if comma == 0 {
+		comma = detectComma(raw)
+	}
+	r := csv.NewReader(bytes.NewReader(raw))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			return nil, &ParseError{Key: "syntax_error", Line: parseErr.Line, Column: parseErr.Column}
+		}
+		return nil, errors.New("syntax_error")
+	}
+	return records, nil
+}
+
+//converts UTF-16 (with byte order mark) and Windows-1252 encoded text to UTF-8
+func decodeText(raw []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(raw, []byte{0xEF, 0xBB, 0xBF}):
+		return raw[3:], nil
+	case bytes.HasPrefix(raw, []byte{0xFF, 0xFE}), bytes.HasPrefix(raw, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Bytes(raw)
+	case utf8.Valid(raw):
+		return raw, nil
+	default:
+		//most spreadsheet programs on windows use this encoding
+		return charmap.Windows1252.NewDecoder().Bytes(raw)
+	}
+}
+
+//returns the separator that occurs most often in the first line outside of quotes
+func detectComma(raw []byte) rune {
+	counts := make(map[rune]int)
+	quoted := false
+	for _, c := range string(raw) {
+		if c == '\n' && !quoted {
+			break
+		}
+		if c == '"' {
+			quoted = !quoted
+		}
+		if !quoted {
+			counts[c]++
+		}
+	}
+	comma := ','
+	for _, c := range []rune{';', '\t', '|'} {
+		if counts[c] > counts[comma] {
+			comma = c
+		}
+	}
+	return comma
+}
+
+//Writes the persons with their assigned group, its rank cost and their preferences as .csv document
+//with slots, there is a column with the assigned group of every slot and the costs are summed up
+func FormatGroupsAndPersonsToCSV(w io.Writer, groups []*matching.Group, persons []*matching.Person, rc matching.RankCosts, l map[string]string) error {
+	c := csv.NewWriter(w)
+	slots := matching.Slots(groups)
+
+	//create header
+	header := []string{l["person name"], l["group_assigned"], l["cost"]}
+	if slots != nil {
+		header = append(append([]string{l["person name"]}, slots...), l["cost"])
+	}
+	for i := 0; i < matching.MaxPreferences(persons); i++ {
+		header = append(header, ChoiceLabel(l, i))
+	}
+	err := c.Write(header)
+	if err != nil {
+		return err
+	}
+
+	//insert persons
+	for _, p := range persons {
+		record := []string{p.Name, "", ""}
+		assigned := p.GetGroup(groups)
+		if slots != nil {
+			record = []string{p.Name}
+			cost := 0
+			for _, slot := range slots {
+				name := ""
+				if g := p.GroupInSlot(groups, slot); g != nil {
+					name = g.Name
+					cost += rc.Cost(p, g)
+				}
+				record = append(record, name)
+			}
+			record = append(record, strconv.Itoa(cost))
+		} else if assigned != nil {
+			record[1] = assigned.Name
+			record[2] = strconv.Itoa(rc.Cost(p, assigned))
+		}
+		for _, pref := range p.Preferences {
+			record = append(record, pref.Name)
+		}
+		err = c.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.Flush()
+	return c.Error()
+}
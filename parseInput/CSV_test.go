@@ -0,0 +1,189 @@
+package parseInput
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/veecue/GroupMatcher/matching"
+)
+
+// returns the groups as "name min-capacity: members" and the persons as "name: wishes"
+func describeProject(project *matching.Project) string {
+	var groups, persons []string
+	for _, g := range project.Groups {
+		var members []string
+		for _, p := range g.Members {
+			members = append(members, p.Name)
+		}
+		groups = append(groups, g.Name+" "+strconv.Itoa(g.MinSize)+"-"+strconv.Itoa(g.Capacity)+": "+strings.Join(members, ", "))
+	}
+	for _, p := range project.Persons {
+		var wishes []string
+		for _, g := range p.Preferences {
+			wishes = append(wishes, g.Name)
+		}
+		persons = append(persons, p.Name+": "+strings.Join(wishes, ", "))
+	}
+	return strings.Join(groups, "; ") + " | " + strings.Join(persons, "; ")
+}
+
+func TestParseCSV(t *testing.T) {
+	withHeadings := DefaultCSVMapping()
+	withHeadings.Name, withHeadings.AssignedHeading, withHeadings.IgnoreHeadings = 1, "Group", []string{"Timestamp"}
+	noHeader := DefaultCSVMapping()
+	noHeader.Header = false
+	columns := DefaultCSVMapping()
+	columns.Preferences = []int{2, 1}
+	sizes := DefaultCSVMapping()
+	sizes.MinSize, sizes.Capacity = 1, 3
+
+	tests := []struct {
+		name    string
+		persons string
+		// the groups file, empty for none
+		groups  string
+		mapping CSVMapping
+		// the project as describeProject returns it, empty if parsing fails
+		want string
+		err  string
+	}{
+		{
+			name:    "header and semicolons",
+			persons: "Name;1st;2nd\nAnna;A;B\nBen;B;A\n",
+			mapping: DefaultCSVMapping(),
+			want:    "A 0-2: ; B 0-2:  | Anna: A, B; Ben: B, A",
+		},
+		{
+			name:    "quoted fields with separators",
+			persons: "Name,Wish 1,Wish 2\n\"Doe, Jane\",A,\"B, C\"\n\"Ben \"\"B\"\"\",\"B, C\",A\n",
+			mapping: DefaultCSVMapping(),
+			want:    "A 0-2: ; B, C 0-2:  | Doe, Jane: A, B, C; Ben \"B\": B, C, A",
+		},
+		{
+			name:    "tabs without header",
+			persons: "Anna\tA\tB\nBen\tB\n",
+			mapping: noHeader,
+			want:    "A 0-2: ; B 0-2:  | Anna: A, B; Ben: B",
+		},
+		{
+			name:    "assigned and ignored columns by their headings",
+			persons: "Timestamp,Name,Group,1,2\n10:00,Anna,B,A,B\n10:05,Ben,,B,A\n",
+			mapping: withHeadings,
+			want:    "A 0-2: ; B 0-2: Anna | Anna: A, B; Ben: B, A",
+		},
+		{
+			name:    "preference columns in rank order",
+			persons: "Name,2nd,1st,Comment\nAnna,B,A,x\n",
+			mapping: columns,
+			want:    "A 0-1: ; B 0-1:  | Anna: A, B",
+		},
+		{
+			name:    "empty cells and lines are skipped",
+			persons: "Name,1,2,3\nAnna,,A,\nBen,B,,B\n,,,\n\n",
+			mapping: DefaultCSVMapping(),
+			want:    "A 0-2: ; B 0-2:  | Anna: A; Ben: B",
+		},
+		{
+			name:    "sizes of created groups",
+			persons: "Name,1\nAnna,A\n",
+			mapping: sizes,
+			want:    "A 1-3:  | Anna: A",
+		},
+		{
+			name:    "groups file",
+			persons: "Name,1,2\nAnna,A,B\n",
+			groups:  "Group,Min,Max\nA,1,2\nB,0,5\nC,0,1\n",
+			mapping: DefaultCSVMapping(),
+			want:    "A 1-2: ; B 0-5: ; C 0-1:  | Anna: A, B",
+		},
+		{
+			name:    "unknown group of the groups file",
+			persons: "Name,1,2\nAnna,A,B\nBen,C,A\n",
+			groups:  "A,0,2\nB,0,2\n",
+			mapping: DefaultCSVMapping(),
+			err:     "group_not_found (3:0)",
+		},
+		{
+			name:    "invalid sizes in the groups file",
+			persons: "Name,1\nAnna,A\n",
+			groups:  "Group,Min,Max\nA,3,2\n",
+			mapping: DefaultCSVMapping(),
+			err:     "csv_groups_error (2:0)",
+		},
+		{
+			name:    "person without preferences",
+			persons: "Name,1,2\nAnna,A,B\nBen,,\n",
+			mapping: DefaultCSVMapping(),
+			err:     "missing_argument (3:0)",
+		},
+		{
+			name:    "person without name",
+			persons: "Name,1\n,A\n",
+			mapping: DefaultCSVMapping(),
+			err:     "empty_argument (2:0)",
+		},
+		{
+			name:    "duplicate person",
+			persons: "Name,1\nAnna,A\nAnna,B\n",
+			mapping: DefaultCSVMapping(),
+			err:     "person_name_not_unique (3:0)",
+		},
+		{
+			name:    "unterminated quote",
+			persons: "Name,1\n\"Anna,A\n",
+			mapping: DefaultCSVMapping(),
+			err:     "syntax_error (2:9)",
+		},
+		{
+			name:    "only the header",
+			persons: "Name,1,2\n",
+			mapping: DefaultCSVMapping(),
+			err:     "empty_file",
+		},
+	}
+
+	for _, test := range tests {
+		var groupsFile io.Reader
+		if test.groups != "" {
+			groupsFile = strings.NewReader(test.groups)
+		}
+		project, err := ParseCSV(strings.NewReader(test.persons), groupsFile, test.mapping)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: error %v, want %s", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := describeProject(project); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+// spreadsheet programs on windows write Windows-1252 and UTF-16 with a byte order mark
+func TestParseCSVEncodings(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{"utf-8 with byte order mark", append([]byte{0xEF, 0xBB, 0xBF}, "Name,1\nJörg,Küche\n"...)},
+		{"windows-1252", []byte("Name,1\nJ\xf6rg,K\xfcche\n")},
+		{"utf-16", []byte("\xff\xfeN\x00a\x00m\x00e\x00,\x001\x00\n\x00J\x00\xf6\x00r\x00g\x00,\x00K\x00\xfc\x00c\x00h\x00e\x00\n\x00")},
+	}
+	for _, test := range tests {
+		project, err := ParseCSV(strings.NewReader(string(test.raw)), nil, DefaultCSVMapping())
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(project.Persons) != 1 || project.Persons[0].Name != "Jörg" || project.Persons[0].Preferences[0].Name != "Küche" {
+			t.Errorf("%s: persons %v", test.name, project.Persons)
+		}
+	}
+}
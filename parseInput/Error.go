@@ -0,0 +1,105 @@
+package parseInput
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//ParseError describes an error in a line of an imported file. Key is the key of the localized message, Token the part
+//of the line that caused the error and Hint the key of the localized description of the expected syntax.
+//Line and Column start with 1, they are 0 if the error doesn't belong to a line (e.g. "empty_file").
+type ParseError struct {
+	Key    string
+	Line   int
+	Column int
+	Token  string
+	Hint   string
+}
+
+//returns the key followed by the position of the error
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return e.Key
+	}
+	return e.Key + " (" + strconv.Itoa(e.Line) + ":" + strconv.Itoa(e.Column) + ")"
+}
+
+//ParseErrors are all errors found while parsing a file in the order of their lines.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "; ")
+}
+
+//returns the lines that contain errors
+func (e ParseErrors) Lines() []int {
+	var lines []int
+	for _, err := range e {
+		if err.Line != 0 && (len(lines) == 0 || lines[len(lines)-1] != err.Line) {
+			lines = append(lines, err.Line)
+		}
+	}
+	return lines
+}
+
+//returns the parse errors contained in the error, nil if it is none
+func AsParseErrors(err error) ParseErrors {
+	switch e := err.(type) {
+	case ParseErrors:
+		return e
+	case *ParseError:
+		return ParseErrors{e}
+	}
+	return nil
+}
+
+//returns an error caused by the token of a line, the position is set by the caller
+func tokenError(key, token string) error {
+	return &ParseError{Key: key, Token: token}
+}
+
+//returns an error in the given line (starting at 1)
+func lineError(key string, line int) error {
+	return &ParseError{Key: key, Line: line}
+}
+
+//converts the error into a parse error in the given line, the column is the one of its token in the text of the line
+//or the first character of the line, counted in characters
+func located(err error, line int, text, hint string) *ParseError {
+	e, ok := err.(*ParseError)
+	if !ok {
+		e = &ParseError{Key: err.Error()}
+	}
+	e.Line, e.Hint = line, hint
+	i := len(text) - len(strings.TrimLeft(text, " \t"))
+	if j := fieldIndex(text, e.Token); j != -1 {
+		i = j
+	}
+	e.Column = utf8.RuneCountInString(text[:i]) + 1
+	return e
+}
+
+//returns the index of the first occurrence of the token as a whole field between the separators ";" and "/",
+//otherwise the index of its first occurrence, -1 if the token is empty or not contained
+func fieldIndex(text, token string) int {
+	if token == "" {
+		return -1
+	}
+	for i := strings.Index(text, token); i != -1; {
+		end := i + len(token)
+		if (i == 0 || strings.ContainsAny(text[i-1:i], ";/ ")) && (end == len(text) || strings.ContainsAny(text[end:end+1], ";/! ")) {
+			return i
+		}
+		j := strings.Index(text[i+1:], token)
+		if j == -1 {
+			break
+		}
+		i += j + 1
+	}
+	return strings.Index(text, token)
+}
@@ -0,0 +1,166 @@
+// Import from excel workbooks as written by FormatGroupsAndPersonsToExcel
+package parseInput
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tealeg/xlsx"
+	"github.com/veecue/GroupMatcher/matching"
+)
+
+//Converts an excel workbook into a project. The groups (name, minimal and maximal size) and the persons (name and
+//preferences) are either read from the first two sheets or from two blocks on the first sheet that are separated by
+//an empty row, as in the total export. Both can start with a row of headings. A filled preference cell marks the group
+//the person is assigned to. Errors contain the row of the sheet. The sixth column of the groups can contain their slot,
+//persons of projects with slots are not assigned as their cells don't tell the slot.
+func ParseExcel(r io.ReaderAt, size int64) (*matching.Project, error) {
+	file, err := xlsx.OpenReaderAt(r, size)
+	if err != nil {
+		return nil, errors.New("excel_error")
+	}
+	if len(file.Sheets) == 0 {
+		return nil, errors.New("empty_file")
+	}
+
+	//find both blocks
+	groupSheet, personSheet := file.Sheets[0], file.Sheets[0]
+	groupRows := nonEmptyRows(groupSheet, 0)
+	personStart := groupRows + 1
+	if len(file.Sheets) > 1 {
+		personSheet = file.Sheets[1]
+		personStart = 0
+	}
+	for personStart < len(personSheet.Rows) && rowEmpty(personSheet.Rows[personStart]) {
+		personStart++
+	}
+	personRows := nonEmptyRows(personSheet, personStart)
+	if groupRows == 0 || personRows == personStart {
+		return nil, errors.New("empty_file")
+	}
+	project := matching.NewProject(make([]*matching.Group, 0), make([]*matching.Person, 0))
+
+	//read groups
+	for i := 0; i < groupRows; i++ {
+		cells := cellValues(groupSheet.Rows[i])
+		if len(cells) < 3 {
+			return nil, lineError("missing_argument", i+1)
+		}
+		min, errMin := strconv.Atoi(cells[1])
+		cap, errCap := strconv.Atoi(cells[2])
+		if errMin != nil || errCap != nil || min < 0 || min > cap {
+			//ignore headings
+			if i == 0 {
+				continue
+			}
+			return nil, lineError("syntax_error", i+1)
+		}
+		if cells[0] == "" {
+			return nil, lineError("empty_argument", i+1)
+		}
+		slot := ""
+		if len(cells) > 5 {
+			slot = cells[5]
+		}
+		if matching.FindGroupInSlot(cells[0], slot, project.Groups) != nil {
+			return nil, lineError("group_name_not_unique", i+1)
+		}
+		g := matching.NewGroup(cells[0], cap, min)
+		g.Slot = slot
+		project.Groups = append(project.Groups, g)
+	}
+
+	//read persons
+	slots := matching.Slots(project.Groups)
+	for i := personStart; i < personRows; i++ {
+		row := personSheet.Rows[i]
+		cells := cellValues(row)
+		//ignore headings, they don't contain any group
+		if i == personStart && !containsGroup(cells[1:], project.Groups) {
+			continue
+		}
+		if len(cells) < 2 {
+			return nil, lineError("missing_argument", i+1)
+		}
+		if cells[0] == "" {
+			return nil, lineError("empty_argument", i+1)
+		}
+		if matching.FindPerson(cells[0], project.Persons) != nil {
+			return nil, lineError("person_name_not_unique", i+1)
+		}
+
+		var prefs []*matching.Group
+		var assigned *matching.Group
+		for j := 1; j < len(cells); j++ {
+			//empty cells are left when preferences are deleted in excel
+			if cells[j] == "" {
+				continue
+			}
+			g := matching.FindGroup(cells[j], project.Groups)
+			if g == nil {
+				return nil, lineError("group_not_found", i+1)
+			}
+			if g.IndexIn(prefs) == -1 {
+				prefs = append(prefs, g)
+			}
+			if cellFilled(row.Cells[j]) && slots == nil {
+				if assigned != nil && assigned != g {
+					return nil, lineError("syntax_error", i+1)
+				}
+				assigned = g
+			}
+		}
+		if len(prefs) == 0 {
+			return nil, lineError("missing_argument", i+1)
+		}
+		p := matching.NewPerson(cells[0], prefs)
+		project.Persons = append(project.Persons, p)
+		if assigned != nil {
+			assigned.Members = append(assigned.Members, p)
+		}
+	}
+	return project, nil
+}
+
+//returns the index of the first empty row at or after start
+func nonEmptyRows(sheet *xlsx.Sheet, start int) int {
+	i := start
+	for i < len(sheet.Rows) && !rowEmpty(sheet.Rows[i]) {
+		i++
+	}
+	return i
+}
+
+func rowEmpty(row *xlsx.Row) bool {
+	return row == nil || strings.Join(cellValues(row), "") == ""
+}
+
+//returns the trimmed values of all cells of a row
+func cellValues(row *xlsx.Row) []string {
+	values := make([]string, len(row.Cells))
+	for i, cell := range row.Cells {
+		if cell != nil {
+			values[i] = strings.TrimSpace(cell.String())
+		}
+	}
+	return values
+}
+
+//checks whether a cell is colored like the active preference of the export
+func cellFilled(cell *xlsx.Cell) bool {
+	if cell == nil {
+		return false
+	}
+	return cell.GetStyle().Fill.PatternType == "solid"
+}
+
+func containsGroup(names []string, groups []*matching.Group) bool {
+	for _, name := range names {
+		if matching.FindGroup(name, groups) != nil {
+			return true
+		}
+	}
+	return false
+}
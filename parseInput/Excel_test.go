@@ -0,0 +1,181 @@
+package parseInput
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tealeg/xlsx"
+	"github.com/veecue/GroupMatcher/matching"
+)
+
+// returns a workbook with the given sheets as it is read from a file
+func newTestWorkbook(t *testing.T, sheets ...[][]string) *bytes.Reader {
+	file := xlsx.NewFile()
+	for i, rows := range sheets {
+		sheet, err := file.AddSheet("Sheet" + strconv.Itoa(i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, values := range rows {
+			row := sheet.AddRow()
+			for _, v := range values {
+				row.AddCell().SetString(v)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := file.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestParseExcelFile(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/project.xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := ParseExcel(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the filled cell assigns Anna to B, empty cells are skipped and the names are trimmed
+	want := "A 1-2: ; B 0-3: Anna; Küche 0-2:  | Anna: A, B; Ben: A, Küche; Cleo: Küche"
+	if got := describeProject(project); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseExcel(t *testing.T) {
+	tests := []struct {
+		name   string
+		sheets [][][]string
+		// the project as describeProject returns it, empty if parsing fails
+		want string
+		err  string
+	}{
+		{
+			name: "two sheets without headings",
+			sheets: [][][]string{
+				{{"A", "0", "2"}, {"B", "1", "2"}},
+				{{"Anna", "A", "B"}, {"Ben", "B"}},
+			},
+			want: "A 0-2: ; B 1-2:  | Anna: A, B; Ben: B",
+		},
+		{
+			name: "blocks on one sheet as in the total export",
+			sheets: [][][]string{{
+				{"Group", "Min", "Max", "Size"},
+				{"A", "0", "2", "0"},
+				{"B", "1", "2", "0"},
+				{},
+				{"Name", "1st", "2nd"},
+				{"Anna", "A", "B"},
+			}},
+			want: "A 0-2: ; B 1-2:  | Anna: A, B",
+		},
+		{
+			name: "empty cells between wishes",
+			sheets: [][][]string{
+				{{"A", "0", "2"}, {"B", "0", "2"}},
+				{{"Anna", "", "B", "", "A"}, {"Ben", "A", "", ""}},
+			},
+			want: "A 0-2: ; B 0-2:  | Anna: B, A; Ben: A",
+		},
+		{
+			name: "unknown group",
+			sheets: [][][]string{
+				{{"A", "0", "2"}},
+				{{"Name", "1st"}, {"Anna", "A"}, {"Ben", "C"}},
+			},
+			err: "group_not_found (3:0)",
+		},
+		{
+			name: "person without wishes",
+			sheets: [][][]string{
+				{{"A", "0", "2"}},
+				{{"Anna", "A"}, {"Ben", "", ""}},
+			},
+			err: "missing_argument (2:0)",
+		},
+		{
+			name: "duplicate group",
+			sheets: [][][]string{
+				{{"A", "0", "2"}, {"A", "0", "3"}},
+				{{"Anna", "A"}},
+			},
+			err: "group_name_not_unique (2:0)",
+		},
+		{
+			name: "duplicate person",
+			sheets: [][][]string{
+				{{"A", "0", "2"}},
+				{{"Anna", "A"}, {"Anna", "A"}},
+			},
+			err: "person_name_not_unique (2:0)",
+		},
+		{
+			name: "invalid sizes",
+			sheets: [][][]string{
+				{{"Group", "Min", "Max"}, {"A", "3", "2"}},
+				{{"Anna", "A"}},
+			},
+			err: "syntax_error (2:0)",
+		},
+		{
+			name:   "no persons",
+			sheets: [][][]string{{{"A", "0", "2"}}},
+			err:    "empty_file",
+		},
+	}
+
+	for _, test := range tests {
+		r := newTestWorkbook(t, test.sheets...)
+		project, err := ParseExcel(r, r.Size())
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: error %v, want %s", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := describeProject(project); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+
+	if _, err := ParseExcel(strings.NewReader("Name,1st"), 8); err == nil || err.Error() != "excel_error" {
+		t.Errorf("a file that isn't a workbook returns %v", err)
+	}
+}
+
+// the total export can be imported again with its assignment
+func TestParseExcelExport(t *testing.T) {
+	a, b := matching.NewGroup("A", 2, 0), matching.NewGroup("B", 2, 1)
+	anna, ben := matching.NewPerson("Anna", []*matching.Group{a, b}), matching.NewPerson("Ben", []*matching.Group{b})
+	b.Members = []*matching.Person{anna}
+	l := map[string]string{"group name": "Group", "min_size": "Min", "max_size": "Max", "group_size": "Size", "cost": "Cost",
+		"person name": "Name", "1stchoice": "1st", "2ndchoice": "2nd", "rate": "Rate"}
+	file, err := FormatGroupsAndPersonsToExcel([]*matching.Group{a, b}, []*matching.Person{anna, ben}, matching.LinearRankCosts(), l, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := file.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	project, err := ParseExcel(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "A 0-2: ; B 1-2: Anna | Anna: A, B; Ben: B"
+	if got := describeProject(project); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -9,12 +9,14 @@ import (
 	"github.com/tealeg/xlsx"
 	"github.com/veecue/GroupMatcher/matching"
 	"io"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 //Converts the current groups and persons (of package matcher) into a .xlsx document and saves into the project folder
-func FormatGroupsAndPersonsToExcel(groups []*matching.Group, persons []*matching.Person, l map[string]string, printTotal bool) (*xlsx.File, error) {
+//the rank costs are used to print the costs of every group and person
+func FormatGroupsAndPersonsToExcel(groups []*matching.Group, persons []*matching.Person, rc matching.RankCosts, l map[string]string, printTotal bool) (*xlsx.File, error) {
 	//create file
 	file := xlsx.NewFile()
 	sheet, err := file.AddSheet("GroupMatcherExport")
@@ -22,6 +24,7 @@ func FormatGroupsAndPersonsToExcel(groups []*matching.Group, persons []*matching
 		fmt.Println(err)
 		return nil, errors.New("export_error")
 	}
+	slots := matching.Slots(groups)
 
 	if printTotal {
 		//create style for active preference
@@ -36,6 +39,10 @@ func FormatGroupsAndPersonsToExcel(groups []*matching.Group, persons []*matching
 		addCell(sheet, len(sheet.Rows)-1, l["min_size"])
 		addCell(sheet, len(sheet.Rows)-1, l["max_size"])
 		addCell(sheet, len(sheet.Rows)-1, l["group_size"])
+		addCell(sheet, len(sheet.Rows)-1, l["cost"])
+		if slots != nil {
+			addCell(sheet, len(sheet.Rows)-1, l["slot"])
+		}
 
 		//insert groups
 		for i := range groups {
@@ -44,37 +51,79 @@ func FormatGroupsAndPersonsToExcel(groups []*matching.Group, persons []*matching
 			addCell(sheet, len(sheet.Rows)-1, strconv.Itoa(groups[i].MinSize))
 			addCell(sheet, len(sheet.Rows)-1, strconv.Itoa(groups[i].Capacity))
 			addCell(sheet, len(sheet.Rows)-1, strconv.Itoa(len(groups[i].Members)))
+			cost := 0
+			for _, p := range groups[i].Members {
+				cost += rc.Cost(p, groups[i])
+			}
+			addCell(sheet, len(sheet.Rows)-1, strconv.Itoa(cost))
+			if slots != nil {
+				addCell(sheet, len(sheet.Rows)-1, groups[i].Slot)
+			}
 		}
 
 		//create persons header
 		sheet.AddRow()
 		sheet.AddRow()
 		addCell(sheet, len(sheet.Rows)-1, l["person name"])
-		addCell(sheet, len(sheet.Rows)-1, l["1stchoice"])
-		addCell(sheet, len(sheet.Rows)-1, l["2ndchoice"])
-		addCell(sheet, len(sheet.Rows)-1, l["3rdchoice"])
+		for i := 0; i < matching.MaxPreferences(persons); i++ {
+			addCell(sheet, len(sheet.Rows)-1, ChoiceLabel(l, i))
+		}
 
 		//insert persons
 		for i := range persons {
 			sheet.AddRow()
 			addCell(sheet, len(sheet.Rows)-1, persons[i].Name)
-			assigned := persons[i].GetGroup(groups)
 			for j := range persons[i].Preferences {
 				addCell(sheet, len(sheet.Rows)-1, persons[i].Preferences[j].Name)
-				//set different style for active preference
-				if assigned != nil {
-					if assigned.Name == persons[i].Preferences[j].Name {
+				//set different style for active preferences, with slots there can be one for every slot
+				for _, g := range groups {
+					if g.Name == persons[i].Preferences[j].Name && persons[i].IndexIn(g.Members) != -1 {
 						sheet.Rows[len(sheet.Rows)-1].Cells[len(sheet.Rows[len(sheet.Rows)-1].Cells)-1].SetStyle(activeStyle)
+						break
 					}
 				}
 			}
 
 		}
+
+		//insert the quote of the whole project
+		m := matching.NewMatcher(persons, groups)
+		m.RankCosts = rc
+		quote, _ := m.CalcQuote()
+		sheet.AddRow()
+		sheet.AddRow()
+		addCell(sheet, len(sheet.Rows)-1, l["rate"])
+		addCell(sheet, len(sheet.Rows)-1, strconv.FormatFloat(quote, 'f', 2, 64))
+	} else if slots != nil {
+		//create persons header with a column for every slot
+		sheet.AddRow()
+		addCell(sheet, len(sheet.Rows)-1, l["person name"])
+		for _, slot := range slots {
+			addCell(sheet, len(sheet.Rows)-1, slot)
+		}
+		addCell(sheet, len(sheet.Rows)-1, l["cost"])
+
+		//insert persons with their group of every slot and the sum of their costs
+		for i := range persons {
+			sheet.AddRow()
+			addCell(sheet, len(sheet.Rows)-1, persons[i].Name)
+			cost := 0
+			for _, slot := range slots {
+				name := ""
+				if g := persons[i].GroupInSlot(groups, slot); g != nil {
+					name = g.Name
+					cost += rc.Cost(persons[i], g)
+				}
+				addCell(sheet, len(sheet.Rows)-1, name)
+			}
+			addCell(sheet, len(sheet.Rows)-1, strconv.Itoa(cost))
+		}
 	} else {
 		//create persons header
 		sheet.AddRow()
 		addCell(sheet, len(sheet.Rows)-1, l["person name"])
 		addCell(sheet, len(sheet.Rows)-1, l["group_assigned"])
+		addCell(sheet, len(sheet.Rows)-1, l["cost"])
 
 		//insert persons
 		for i := range persons {
@@ -83,6 +132,7 @@ func FormatGroupsAndPersonsToExcel(groups []*matching.Group, persons []*matching
 			assigned := persons[i].GetGroup(groups)
 			if assigned != nil {
 				addCell(sheet, len(sheet.Rows)-1, assigned.Name)
+				addCell(sheet, len(sheet.Rows)-1, strconv.Itoa(rc.Cost(persons[i], assigned)))
 			}
 		}
 	}
@@ -90,17 +140,58 @@ func FormatGroupsAndPersonsToExcel(groups []*matching.Group, persons []*matching
 	return file, nil
 }
 
-//Converts the current groups and persons (of package matcher) into a string in .gm syntax.
-func FormatGroupsAndPersons(groups []*matching.Group, persons []*matching.Person) (string, error) {
+//Converts the current groups and persons (of package matcher) into a string in the given version of the .gm syntax.
+func FormatGroupsAndPersons(groups []*matching.Group, persons []*matching.Person, version int) (string, error) {
+	return FormatProjectVersion(matching.NewProject(groups, persons), version)
+}
+
+//Converts a whole project into a string in the current version of the .gm syntax.
+func FormatProject(project *matching.Project) (string, error) {
+	return FormatProjectVersion(project, int(current))
+}
+
+//Converts a whole project into a string in the given version of the .gm syntax. Options that differ from the defaults are written as key=value lines in front of the groups.
+//Version 1 can't contain metadata and names with separators, for such names "legacy_name" is returned.
+func FormatProjectVersion(project *matching.Project, version int) (string, error) {
+	groups, persons := project.Groups, project.Persons
+	sx := syntax(version)
 	// buffer for efficient string concatenation
 	var buf bytes.Buffer
 	r := &buf
+	if sx != legacy && sx != current {
+		return "", errors.New("unsupported_version")
+	}
 	if len(groups) == 0 {
 		return "", errors.New("groups_empty")
 	}
 	if len(persons) == 0 {
 		return "", errors.New("persons_empty")
 	}
+	if sx == legacy {
+		for _, g := range groups {
+			if strings.ContainsAny(g.Name+g.Slot, ";/") {
+				return "", errors.New("legacy_name")
+			}
+		}
+		for _, p := range persons {
+			if strings.ContainsAny(p.Name, ";/") {
+				return "", errors.New("legacy_name")
+			}
+		}
+	}
+
+	// print the version header and the metadata
+	if sx != legacy {
+		fmt.Fprintf(r, "%s;%d\n", versionHeader, sx)
+		keys := make([]string, 0, len(project.Metadata))
+		for k := range project.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintln(r, "M;"+sx.quote(k)+"="+sx.quote(project.Metadata[k]))
+		}
+	}
 
 	// check whether all groups have the same parameters
 	uniformMinMax := true
@@ -111,6 +202,11 @@ func FormatGroupsAndPersons(groups []*matching.Group, persons []*matching.Person
 			uniformMinMax = false
 		}
 	}
+	// print options
+	for _, pair := range project.Options.Pairs() {
+		fmt.Fprintln(r, pair[0]+"="+pair[1])
+	}
+
 	fmt.Fprint(r, "S")
 	if uniformMinMax {
 		fmt.Fprintf(r, ";%d;%d", groups[0].MinSize, groups[0].Capacity)
@@ -119,9 +215,15 @@ func FormatGroupsAndPersons(groups []*matching.Group, persons []*matching.Person
 	// print group definition
 	fmt.Fprintln(r)
 
-	// print grous
+	// print grous, every slot starts with a slot initializer
+	slots := matching.Slots(groups)
+	slot := ""
 	for _, g := range groups {
-		fmt.Fprint(r, g.Name)
+		if g.Slot != slot {
+			fmt.Fprintln(r, "T;"+sx.quote(g.Slot))
+			slot = g.Slot
+		}
+		fmt.Fprint(r, sx.quote(g.Name))
 		if !uniformMinMax {
 			fmt.Fprintf(r, ";%d;%d", g.MinSize, g.Capacity)
 		}
@@ -131,25 +233,148 @@ func FormatGroupsAndPersons(groups []*matching.Group, persons []*matching.Person
 	// print persons
 	fmt.Fprintln(r, "P")
 	for _, p := range persons {
-		fmt.Fprint(r, p.Name)
+		fmt.Fprint(r, sx.quote(p.Name))
 		for _, pref := range p.Preferences {
-			fmt.Fprint(r, ";"+pref.Name)
+			fmt.Fprint(r, ";"+sx.quote(pref.Name))
+		}
+		if slots != nil {
+			// print the group of every slot, trailing slots without group are left out
+			assigned := make([]string, len(slots))
+			last := -1
+			for i, slot := range slots {
+				if g := p.GroupInSlot(groups, slot); g != nil {
+					assigned[i] = assignedName(sx, p, g)
+					last = i
+				}
+			}
+			if last != -1 {
+				fmt.Fprint(r, "/"+strings.Join(assigned[:last+1], "/"))
+			}
+			fmt.Fprintln(r)
+			continue
 		}
 		g := p.GetGroup(groups)
 		if g != nil {
-			fmt.Fprintln(r, "/"+g.Name)
+			fmt.Fprintln(r, "/"+assignedName(sx, p, g))
 		} else {
 			fmt.Fprintln(r)
 		}
 	}
+
+	// print attributes
+	attributesHeader := false
+	for _, p := range persons {
+		if len(p.Attributes) == 0 {
+			continue
+		}
+		if !attributesHeader {
+			fmt.Fprintln(r, "A")
+			attributesHeader = true
+		}
+		fmt.Fprint(r, sx.quote(p.Name))
+		keys := make([]string, 0, len(p.Attributes))
+		for k := range p.Attributes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprint(r, ";"+sx.quote(k)+"="+sx.quote(p.Attributes[k]))
+		}
+		fmt.Fprintln(r)
+	}
+
+	// print friends
+	friendsHeader := false
+	for _, p := range persons {
+		if len(p.Friends) == 0 {
+			continue
+		}
+		if !friendsHeader {
+			fmt.Fprintln(r, "F")
+			friendsHeader = true
+		}
+		fmt.Fprint(r, sx.quote(p.Name))
+		for _, f := range p.Friends {
+			fmt.Fprint(r, ";"+sx.quote(f.Name))
+		}
+		fmt.Fprintln(r)
+	}
+
+	// print constraints and balance rules
+	if len(project.Constraints) > 0 || len(project.Balance) > 0 {
+		fmt.Fprintln(r, "C")
+		for _, c := range project.Constraints {
+			fmt.Fprint(r, c.Kind)
+			for _, p := range c.Persons {
+				fmt.Fprint(r, ";"+sx.quote(p.Name))
+			}
+			fmt.Fprintln(r)
+		}
+		for _, b := range project.Balance {
+			fmt.Fprintln(r, formatBalanceRule(sx, b))
+		}
+	}
 	return buf.String(), nil
 }
 
+//returns the name of the group the person is assigned to, followed by "!" if the assignment is locked
+func assignedName(sx syntax, p *matching.Person, g *matching.Group) string {
+	if g.IsLocked(p) {
+		return sx.quote(g.Name) + "!"
+	}
+	return sx.quote(g.Name)
+}
+
+//returns the balance rule in the syntax of parseBalanceRule
+func formatBalanceRule(sx syntax, b *matching.BalanceRule) string {
+	if sx == legacy {
+		return b.String()
+	}
+	s := "max"
+	if b.AtLeast {
+		s = "min"
+	}
+	s += ";" + sx.quote(b.Key)
+	if b.Value != "" {
+		s += "=" + sx.quote(b.Value)
+	}
+	s += ";" + strconv.Itoa(b.Amount)
+	if b.Percent {
+		s += "%"
+	}
+	if b.Group != nil {
+		s += ";" + sx.quote(b.Group.Name)
+	}
+	return s
+}
+
+//keys of the localized syntax descriptions of the lines in every reading mode
+var hints = []string{"hint_option", "hint_person", "hint_group", "hint_constraint", "hint_attributes", "hint_friends"}
+
 //Converts the imported data into slices of groups and persons (package matcher).
 func ParseGroupsAndPersons(data io.Reader) ([]*matching.Group, []*matching.Person, error) {
+	project, err := ParseProject(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return project.Groups, project.Persons, nil
+}
+
+//Converts the imported data into a project. Lines in front of the group initializer can contain options in key=value syntax.
+//Groups can be divided into slots by lines with the slot initializer "T;name", then persons list their group of every slot.
+//The persons can be followed by their attributes after the attribute initializer "A", by their friends after the
+//friend initializer "F" and by constraints between them and balance rules after the constraint initializer "C".
+//Files that start with a version header are read in that version of the syntax (see syntax), others as version 1.
+//The errors of all lines are returned together as ParseErrors.
+func ParseProject(data io.Reader) (*matching.Project, error) {
 	//init return slices
 	var groups []*matching.Group
 	var persons []*matching.Person
+	var constraints []*matching.Constraint
+	var balance []*matching.BalanceRule
+	var metadata map[string]string
+	options := matching.DefaultOptions()
+	sx := legacy
 
 	//convert data into bufio scanner
 	scanner := bufio.NewScanner(data)
@@ -158,6 +383,13 @@ func ParseGroupsAndPersons(data io.Reader) ([]*matching.Group, []*matching.Perso
 	emptyFile := true
 	var count, mode, minSize, capacity int
 	var foundGroups, foundPersons bool
+	var slot string
+
+	//errors are collected so that all bad lines can be shown at once, a line with an error is skipped
+	var errs ParseErrors
+	fail := func(err error, raw string) {
+		errs = append(errs, located(err, count, raw, hints[mode]))
+	}
 
 	//do as long as there are lines
 	for scanner.Scan() {
@@ -167,8 +399,26 @@ func ParseGroupsAndPersons(data io.Reader) ([]*matching.Group, []*matching.Perso
 		emptyFile = false
 
 		//get line text and remove whitespaces
-		text := scanner.Text()
-		text = strings.TrimSpace(text)
+		raw := scanner.Text()
+		text := strings.TrimSpace(raw)
+
+		//the version header has to be the first line
+		if count == 1 {
+			if params := strings.Split(text, ";"); params[0] == versionHeader {
+				if len(params) != 2 || params[1] != "2" {
+					errs = append(errs, located(tokenError("unsupported_version", text), count, raw, ""))
+					return nil, errs
+				}
+				sx = current
+				continue
+			}
+		}
+		//comments are skipped since version 2
+		if sx != legacy && strings.HasPrefix(text, "#") {
+			continue
+		}
+		//the first field decides about initializers, the group initializer is only the first field "S" since version 2
+		first := sx.splitN(text, ';', 2)[0]
 
 		if len(text) != 0 {
 			//if line contains person initializer set reading mode to 1, foundPersons to true and continue with next line
@@ -177,86 +427,161 @@ func ParseGroupsAndPersons(data io.Reader) ([]*matching.Group, []*matching.Perso
 				foundPersons = true
 				continue
 			}
+			//if line contains constraint initializer after the persons set reading mode to 3 and continue with next line
+			if text == "C" && foundPersons {
+				mode = 3
+				continue
+			}
+			//if line contains attribute initializer after the persons set reading mode to 4 and continue with next line
+			if text == "A" && foundPersons {
+				mode = 4
+				continue
+			}
+			//if line contains friend initializer after the persons set reading mode to 5 and continue with next line
+			if text == "F" && foundPersons {
+				mode = 5
+				continue
+			}
 			//if line contains group initializer set reading mode to 2, set group parameters, check them for compatibility, and continue with next line
-			if strings.HasPrefix(text, "S") && !foundGroups {
+			if (strings.HasPrefix(text, "S") && sx == legacy || first == "S") && !foundGroups {
 				mode = 2
 				//var initializer string
 				//initializer, minSize, capacity = parseGroupParams(text)
-				_, minSize, capacity = parseGroupParams(text)
+				_, minSize, capacity = parseGroupParams(sx, text)
 
 				if (minSize == -1 && capacity == -1) || minSize > capacity {
-					errString := "syntax_error" + strconv.Itoa(count)
-					return nil, nil, errors.New(errString)
+					errs = append(errs, located(tokenError("syntax_error", text), count, raw, "hint_group_initializer"))
+					minSize, capacity = 0, 0
 				}
 				foundGroups = true
 				continue
 			}
 			switch mode {
+			case 0:
+				//metadata of the project since version 2
+				if first == "M" && sx != legacy {
+					params := sx.split(text, ';')
+					pair := sx.splitN(params[len(params)-1], '=', 2)
+					if len(params) != 2 || len(pair) != 2 {
+						fail(errors.New("syntax_error"), raw)
+						continue
+					}
+					key := sx.value(strings.TrimSpace(pair[0]))
+					if key == "" {
+						fail(errors.New("empty_argument"), raw)
+						continue
+					}
+					if metadata == nil {
+						metadata = make(map[string]string)
+					}
+					metadata[key] = sx.value(strings.TrimSpace(pair[1]))
+					continue
+				}
+				//parse option in front of the groups, other lines are ignored as before (an error since version 2), as are
+				//unknown options in version 1, which were arbitrary lines for older versions of the program
+				pair := sx.splitN(text, '=', 2)
+				if len(pair) == 2 {
+					err := options.Set(strings.TrimSpace(sx.value(pair[0])), strings.TrimSpace(sx.value(pair[1])))
+					if err != nil && (sx != legacy || err.Error() != "unknown_setting") {
+						fail(err, raw)
+					}
+				} else if sx != legacy {
+					fail(errors.New("syntax_error"), raw)
+				}
 			case 1:
 				//parse person from line
-				person, err := parsePerson(text, groups, persons)
+				person, err := parsePerson(sx, text, groups, persons)
 				if err != nil {
-					var errString string
 					if !foundGroups {
 						//in case groups were not declared before person initializer was found
-						return nil, nil, errors.New("group_initializer_not_found")
-					} else {
-						//otherwise add line number to error message
-						errString = err.Error() + strconv.Itoa(count)
+						return nil, ParseErrors{{Key: "group_initializer_not_found"}}
 					}
-					return nil, nil, errors.New(errString)
+					fail(err, raw)
 				} else {
 					//if no error occured add person to persons slice
 					persons = append(persons, person)
 				}
 			case 2:
+				//a line with the slot initializer assigns the following groups to the slot
+				if params := sx.split(text, ';'); len(params) == 2 && params[0] == "T" {
+					slot = sx.value(strings.TrimSpace(params[1]))
+					continue
+				}
 				//parse group form line
-				group, err := parseGroup(text, minSize, capacity)
+				group, err := parseGroup(sx, text, minSize, capacity)
 				if err != nil {
-					//if error occured add line number to error message
-					errString := err.Error() + strconv.Itoa(count)
-
-					//if parsePerson() returns no error the person initializer was probably not found
-					_, e := parsePerson(text, groups, persons)
-					if e == nil {
-						errString = "person_initializer_not_found"
+					//if parsePerson() returns no error the person initializer was probably not found,
+					//all following lines would be wrong
+					if _, e := parsePerson(sx, text, groups, persons); e == nil {
+						errs = append(errs, located(errors.New("person_initializer_not_found"), count, raw, "hint_person_initializer"))
+						return nil, errs
 					}
-					return nil, nil, errors.New(errString)
+					fail(err, raw)
 				} else {
-					//check for double use of a group name
-					if matching.FindGroup(group.Name, groups) != nil {
-						errString := "group_name_not_unique" + strconv.Itoa(count)
-						return nil, nil, errors.New(errString)
+					//check for double use of a group name in the same slot
+					group.Slot = slot
+					if matching.FindGroupInSlot(group.Name, slot, groups) != nil {
+						fail(tokenError("group_name_not_unique", group.Name), raw)
+						continue
 					}
 					//if no error occured add group to groups slice
 					groups = append(groups, group)
 				}
-
+			case 3:
+				//parse balance rule or constraint from line
+				if first == "min" || first == "max" {
+					rule, err := parseBalanceRule(sx, text, groups)
+					if err != nil {
+						fail(err, raw)
+						continue
+					}
+					balance = append(balance, rule)
+					continue
+				}
+				constraint, err := parseConstraint(sx, text, persons)
+				if err != nil {
+					fail(err, raw)
+					continue
+				}
+				constraints = append(constraints, constraint)
+			case 4:
+				//parse attributes of a person from line
+				err := parseAttributes(sx, text, persons)
+				if err != nil {
+					fail(err, raw)
+				}
+			case 5:
+				//parse friends of a person from line
+				err := parseFriends(sx, text, persons)
+				if err != nil {
+					fail(err, raw)
+				}
 			}
 		}
 	}
 
 	//if file was empty return appropriate error message
 	if emptyFile {
-		err := errors.New("empty_file")
-		return nil, nil, err
+		return nil, ParseErrors{{Key: "empty_file"}}
 	}
 
 	//if persons initializer was not found return appropriate error message
 	if !foundPersons {
-		err := errors.New("person_initializer_not_found")
-		return nil, nil, err
+		errs = append(errs, &ParseError{Key: "person_initializer_not_found", Hint: "hint_person_initializer"})
+	}
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
-	//if no error occured return groups and persons
-	return groups, persons, nil
+	//if no error occured return the project
+	return &matching.Project{Groups: groups, Persons: persons, Constraints: constraints, Balance: balance, Options: options, Metadata: metadata}, nil
 }
 
 //Converts a single line (that should contain ether the group initializer or a group itself) into its parameters.
-func parseGroupParams(str string) (string, int, int) {
-	s := strings.Split(str, ";")
+func parseGroupParams(sx syntax, str string) (string, int, int) {
+	s := sx.split(str, ';')
 	if len(s) == 1 {
-		return s[0], 0, 0
+		return sx.value(s[0]), 0, 0
 	}
 	if len(s) != 3 {
 		return "", -1, -1
@@ -269,65 +594,203 @@ func parseGroupParams(str string) (string, int, int) {
 	if err != nil {
 		return "", -1, -1
 	}
-	return s[0], min, cap
+	return sx.value(s[0]), min, cap
 }
 
 //Converts a single line (that should contain a person) into its parameters.
-func parsePerson(str string, groups []*matching.Group, persons []*matching.Person) (*matching.Person, error) {
-	params := strings.Split(str, ";")
+func parsePerson(sx syntax, str string, groups []*matching.Group, persons []*matching.Person) (*matching.Person, error) {
+	params := sx.split(str, ';')
 	if len(params) < 2 {
 		return nil, errors.New("missing_argument")
 	}
 
-	var assignTo *matching.Group
+	//with slots, the groups the person is assigned to are given for every slot in their order, empty if it has none
+	//a "!" behind the group locks the assignment
+	var assignTo, lockIn []*matching.Group
+	slots := matching.Slots(groups)
 	lastIndex := len(params) - 1
-	s := strings.Split(params[lastIndex], "/")
-	if len(s) > 2 {
-		return nil, errors.New("syntax_error")
+	s := sx.split(params[lastIndex], '/')
+	if len(s) > 2 && len(s) > len(slots)+1 {
+		return nil, tokenError("syntax_error", params[lastIndex])
 	}
 	if len(s) > 1 {
 		params[lastIndex] = s[0]
-		assignTo = matching.FindGroup(s[1], groups)
-		if assignTo == nil {
-			return nil, errors.New("group_not_found")
+		for i, name := range s[1:] {
+			if slots != nil && name == "" {
+				continue
+			}
+			slot := ""
+			if slots != nil {
+				slot = slots[i]
+			}
+			g := matching.FindGroupInSlot(sx.value(name), slot, groups)
+			locked := false
+			if unlocked, ok := sx.locked(name); g == nil && ok {
+				g = matching.FindGroupInSlot(sx.value(unlocked), slot, groups)
+				locked = true
+			}
+			if g == nil {
+				return nil, tokenError("group_not_found", name)
+			}
+			assignTo = append(assignTo, g)
+			if locked {
+				lockIn = append(lockIn, g)
+			}
 		}
 	}
 
 	for _, a := range params {
-		if a == "" {
+		if sx.value(a) == "" {
 			return nil, errors.New("empty_argument")
 		}
 	}
 
 	var prefs []*matching.Group
 	for _, a := range params[1:] {
-		prefs = append(prefs, matching.FindGroup(a, groups))
-	}
-
-	for _, g := range prefs {
+		g := matching.FindGroup(sx.value(a), groups)
 		if g == nil {
-			return nil, errors.New("group_not_found")
+			return nil, tokenError("group_not_found", a)
 		}
+		prefs = append(prefs, g)
 	}
 
-	p := matching.NewPerson(string(params[0]), prefs)
+	p := matching.NewPerson(sx.value(params[0]), prefs)
 
 	if matching.FindPerson(p.Name, persons) != nil {
-		return nil, errors.New("person_name_not_unique")
+		return nil, tokenError("person_name_not_unique", params[0])
+	}
+	for _, g := range assignTo {
+		g.Members = append(g.Members, p)
 	}
-	if assignTo != nil {
-		assignTo.Members = append(assignTo.Members, p)
+	for _, g := range lockIn {
+		g.Locked = append(g.Locked, p)
 	}
 
 	return p, nil
 }
 
+//Converts a single line (that should contain a constraint) into a constraint between the given persons.
+func parseConstraint(sx syntax, str string, persons []*matching.Person) (*matching.Constraint, error) {
+	params := sx.split(str, ';')
+	if params[0] != matching.Together && params[0] != matching.Apart {
+		return nil, tokenError("syntax_error", params[0])
+	}
+	if len(params) < 3 {
+		return nil, errors.New("missing_argument")
+	}
+
+	var members []*matching.Person
+	for _, a := range params[1:] {
+		if sx.value(a) == "" {
+			return nil, errors.New("empty_argument")
+		}
+		p := matching.FindPerson(sx.value(a), persons)
+		if p == nil {
+			return nil, tokenError("person_not_found", a)
+		}
+		if p.IndexIn(members) != -1 {
+			return nil, tokenError("person_name_not_unique", a)
+		}
+		members = append(members, p)
+	}
+
+	return matching.NewConstraint(params[0], members), nil
+}
+
+//Converts a single line (that should contain a balance rule) into a balance rule for the given groups.
+func parseBalanceRule(sx syntax, str string, groups []*matching.Group) (*matching.BalanceRule, error) {
+	if sx == legacy {
+		return matching.ParseBalanceRule(str, groups)
+	}
+	params := sx.split(str, ';')
+	if len(params) < 3 {
+		return nil, errors.New("missing_argument")
+	}
+	if len(params) > 4 {
+		return nil, errors.New("syntax_error")
+	}
+	attribute := sx.splitN(params[1], '=', 2)
+	key, value := sx.value(strings.TrimSpace(attribute[0])), ""
+	if len(attribute) == 2 {
+		value = sx.value(strings.TrimSpace(attribute[1]))
+		if value == "" {
+			return nil, errors.New("empty_argument")
+		}
+	}
+	group := ""
+	if len(params) == 4 {
+		group = sx.value(params[3])
+		if group == "" {
+			return nil, errors.New("empty_argument")
+		}
+	}
+	return matching.ParseBalanceFields(params[0], key, value, params[2], group, groups)
+}
+
+//Converts a single line (that should contain a person name and key=value pairs) into attributes of the person.
+func parseAttributes(sx syntax, str string, persons []*matching.Person) error {
+	params := sx.split(str, ';')
+	if len(params) < 2 {
+		return errors.New("missing_argument")
+	}
+	p := matching.FindPerson(sx.value(params[0]), persons)
+	if p == nil {
+		return tokenError("person_not_found", params[0])
+	}
+	if p.Attributes == nil {
+		p.Attributes = make(map[string]string)
+	}
+	for _, a := range params[1:] {
+		s := sx.splitN(a, '=', 2)
+		if len(s) != 2 {
+			return tokenError("syntax_error", a)
+		}
+		key, value := sx.value(strings.TrimSpace(s[0])), sx.value(strings.TrimSpace(s[1]))
+		if key == "" || value == "" {
+			return errors.New("empty_argument")
+		}
+		p.Attributes[key] = value
+	}
+	return nil
+}
+
+//Converts a single line (that should contain a person name followed by the names of its friends) into friends of the person.
+func parseFriends(sx syntax, str string, persons []*matching.Person) error {
+	params := sx.split(str, ';')
+	if len(params) < 2 {
+		return errors.New("missing_argument")
+	}
+	for _, a := range params {
+		if sx.value(a) == "" {
+			return errors.New("empty_argument")
+		}
+	}
+	p := matching.FindPerson(sx.value(params[0]), persons)
+	if p == nil {
+		return tokenError("person_not_found", params[0])
+	}
+	for _, a := range params[1:] {
+		f := matching.FindPerson(sx.value(a), persons)
+		if f == nil {
+			return tokenError("person_not_found", a)
+		}
+		if f == p || f.IndexIn(p.Friends) != -1 {
+			return tokenError("person_name_not_unique", a)
+		}
+		p.Friends = append(p.Friends, f)
+	}
+	return nil
+}
+
 //Converts the parameters it gets from parseGroupParams() into a new group (package matcher) handling any errors.
-func parseGroup(str string, minSize, capacity int) (*matching.Group, error) {
-	name, min, cap := parseGroupParams(str)
+func parseGroup(sx syntax, str string, minSize, capacity int) (*matching.Group, error) {
+	name, min, cap := parseGroupParams(sx, str)
 
 	if min < 0 && cap < 0 {
-		return nil, errors.New("syntax_error")
+		return nil, tokenError("syntax_error", str)
+	}
+	if name == "" {
+		return nil, errors.New("empty_argument")
 	}
 
 	if min == 0 && cap == 0 {
@@ -338,6 +801,20 @@ func parseGroup(str string, minSize, capacity int) (*matching.Group, error) {
 	return matching.NewGroup(name, cap, min), nil
 }
 
+//returns the localized heading for the preference with the given index (starting at 0)
+func ChoiceLabel(l map[string]string, i int) string {
+	switch i {
+	case 0:
+		return l["1stchoice"]
+	case 1:
+		return l["2ndchoice"]
+	case 2:
+		return l["3rdchoice"]
+	default:
+		return fmt.Sprintf(l["nthchoice"], i+1)
+	}
+}
+
 //adds a Cell to the given row of a .xlsx sheet
 func addCell(sheet *xlsx.Sheet, row int, value string) {
 	sheet.Rows[row].AddCell()
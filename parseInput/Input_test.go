@@ -0,0 +1,60 @@
+package parseInput
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseProjectOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		// the solver of the parsed project, empty if parsing fails
+		solver string
+	}{
+		{
+			name:   "legacy file with options",
+			file:   "solver=exact\nS;0;3\nA\nP\na;A\n",
+			solver: "exact",
+		},
+		{
+			name:   "legacy file with unknown keys and arbitrary lines",
+			file:   "title=Old project\ncreated by hand\nsolver=exact\nS;0;3\nA\nP\na;A\n",
+			solver: "exact",
+		},
+		{
+			name: "legacy file with an invalid value",
+			file: "solver=unknown\nS;0;3\nA\nP\na;A\n",
+		},
+		{
+			name:   "version 2 file with options",
+			file:   "GM;2\nsolver=exact\nS;0;3\nA\nP\na;A\n",
+			solver: "exact",
+		},
+		{
+			name: "version 2 file with an unknown key",
+			file: "GM;2\ntitle=x\nS;0;3\nA\nP\na;A\n",
+		},
+		{
+			name: "version 2 file with an arbitrary line",
+			file: "GM;2\ncreated by hand\nS;0;3\nA\nP\na;A\n",
+		},
+	}
+
+	for _, test := range tests {
+		project, err := ParseProject(strings.NewReader(test.file))
+		if test.solver == "" {
+			if err == nil {
+				t.Errorf("%s: no error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if project.Options.Solver != test.solver || len(project.Groups) != 1 || len(project.Persons) != 1 {
+			t.Errorf("%s: solver %s with %d groups and %d persons", test.name, project.Options.Solver, len(project.Groups), len(project.Persons))
+		}
+	}
+}
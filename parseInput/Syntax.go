@@ -0,0 +1,103 @@
+package parseInput
+
+import "strings"
+
+//syntax is the version of the .gm format. Version 1 splits lines at every separator. Files of version 2 start with
+//the header "GM;2", can contain comments (lines starting with "#") and metadata ("M;key=value"), and fields can be
+//quoted ("a;b", a quote is written as "") or contain characters escaped with a backslash (a\;b).
+type syntax int
+
+const (
+	legacy  syntax = 1
+	current syntax = 2
+)
+
+//the first field of the version header
+const versionHeader = "GM"
+
+//names that have to be quoted in version 2 as they would be read as initializers
+var keywords = []string{"S", "P", "C", "A", "F", "T", "M", versionHeader}
+
+//splits the line at every separator that isn't quoted or escaped, the fields keep their quotes and escapes
+func (s syntax) split(str string, sep byte) []string {
+	return s.splitN(str, sep, -1)
+}
+
+//splits the line into at most n fields like split, all fields for n < 0
+func (s syntax) splitN(str string, sep byte, n int) []string {
+	if s == legacy {
+		return strings.SplitN(str, string(sep), n)
+	}
+	var fields []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(str) && (n < 0 || len(fields) < n-1); i++ {
+		switch {
+		case str[i] == '\\':
+			i++
+		case str[i] == '"':
+			quoted = !quoted
+		case str[i] == sep && !quoted:
+			fields = append(fields, str[start:i])
+			start = i + 1
+		}
+	}
+	return append(fields, str[start:])
+}
+
+//returns the value of a field without its quotes and escapes
+func (s syntax) value(field string) string {
+	if s == legacy {
+		return field
+	}
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(field); i++ {
+		switch {
+		case field[i] == '\\' && i+1 < len(field):
+			i++
+			b.WriteByte(field[i])
+		case field[i] == '"' && quoted && i+1 < len(field) && field[i+1] == '"':
+			i++
+			b.WriteByte('"')
+		case field[i] == '"':
+			quoted = !quoted
+		default:
+			b.WriteByte(field[i])
+		}
+	}
+	return b.String()
+}
+
+//returns the field without a "!" at its end that locks an assignment, in version 2 the "!" must not be quoted or escaped
+func (s syntax) locked(field string) (string, bool) {
+	if !strings.HasSuffix(field, "!") {
+		return field, false
+	}
+	if s != legacy {
+		//an odd number of backslashes escapes the "!"
+		escapes := len(field) - 1 - len(strings.TrimRight(field[:len(field)-1], "\\"))
+		if escapes%2 == 1 {
+			return field, false
+		}
+	}
+	return strings.TrimSuffix(field, "!"), true
+}
+
+//returns the name as a field that is read as the name again, in version 2 names with separators, quotes, leading or
+//trailing spaces and names of initializers are quoted
+func (s syntax) quote(name string) string {
+	if s == legacy {
+		return name
+	}
+	needsQuotes := name == "" || strings.ContainsAny(name, ";/=!\"\\#") || strings.TrimSpace(name) != name
+	for _, k := range keywords {
+		if name == k {
+			needsQuotes = true
+		}
+	}
+	if !needsQuotes {
+		return name
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `""`).Replace(name) + `"`
+}
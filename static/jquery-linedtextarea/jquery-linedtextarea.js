@@ -18,6 +18,7 @@
  *   
  *   $(".lined").linedtextarea({
  *   	selectedLine: 10,
+ *    selectedLines: [10, 12],
  *    selectedClass: 'lineselect'
  *   });
  *
@@ -41,7 +42,7 @@
 		 */
 		var fillOutLines = function(codeLines, h, lineNo){
 			while ( (codeLines.height() - h ) <= 0 ){
-				if ( lineNo == opts.selectedLine )
+				if ( lineNo == opts.selectedLine || $.inArray(lineNo, opts.selectedLines) != -1 )
 					codeLines.append("<div class='lineno lineselect'>" + lineNo + "</div>");
 				else
 					codeLines.append("<div class='lineno'>" + lineNo + "</div>");
@@ -121,6 +122,7 @@
   // default options
   $.fn.linedtextarea.defaults = {
   	selectedLine: -1,
+  	selectedLines: [],
   	selectedClass: 'lineselect'
   };
 })(jQuery);
\ No newline at end of file